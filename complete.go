@@ -274,6 +274,7 @@ func (o *opCompleter) setColumnInfo() {
 func (o *opCompleter) needPagerMode() bool {
 	tWidth, tHeight := o.w.GetWidthHeight()
 	buflineCnt := o.op.buf.LineCount()           // lines taken by buffer content
+	buflineCnt += o.op.buf.StatusLineCount()     // and the status below it
 	linesAvail := tHeight - buflineCnt           // lines available without scrolling buffer off screen
 	if o.candidateColNum > 0 {
 		// Normal case where each candidate at least fits on a line
@@ -373,7 +374,7 @@ func (o *opCompleter) CompleteRefresh() {
 	}
 
 	// wrote out choices over "lines", move back to cursor (positioned at index)
-	fmt.Fprintf(buf, "\033[%dA", lines)
+	fmt.Fprintf(buf, "\033[%dA", lines+o.op.buf.StatusLineCount())
 	buf.Write(o.op.buf.getBackspaceSequence())
 	buf.Flush()
 }
@@ -0,0 +1,125 @@
+package readline
+
+import (
+	"strings"
+	"unicode"
+)
+
+// EditRules customize editing for a language, see Config.EditRules.
+type EditRules interface {
+	// Indent returns the indentation of a new line inserted after line,
+	// the part of the current line before the cursor.
+	Indent(line []rune) []rune
+	// Pairs returns the runes which are closed automatically, each opening
+	// rune followed by its closing rune, for example "()[]{}\"\"".
+	Pairs() string
+}
+
+// BracketEditRules are EditRules for languages with blocks in brackets.
+// A new line keeps the indentation of the line before and is indented by
+// IndentUnit more if that line ends with an opening bracket.
+type BracketEditRules struct {
+	PairRunes  string // defaults to ()[]{} and double quotes if empty
+	IndentUnit string // defaults to four spaces if empty
+}
+
+func (b *BracketEditRules) Pairs() string {
+	if b.PairRunes == "" {
+		return "()[]{}\"\""
+	}
+	return b.PairRunes
+}
+
+func (b *BracketEditRules) Indent(line []rune) []rune {
+	indent := leadingSpace(line)
+	trimmed := strings.TrimRightFunc(string(line), unicode.IsSpace)
+	if trimmed == "" {
+		return indent
+	}
+	last := []rune(trimmed)
+	if c := pairCloser(b, last[len(last)-1]); c != 0 && c != last[len(last)-1] {
+		unit := b.IndentUnit
+		if unit == "" {
+			unit = "    "
+		}
+		indent = append(indent, []rune(unit)...)
+	}
+	return indent
+}
+
+func leadingSpace(line []rune) []rune {
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return runes.Copy(line[:i])
+}
+
+// pairCloser returns the rune closing the pair opened by r, or 0.
+func pairCloser(rules EditRules, r rune) rune {
+	pairs := []rune(rules.Pairs())
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i] == r {
+			return pairs[i+1]
+		}
+	}
+	return 0
+}
+
+// isPairCloser returns true if r closes a pair.
+func isPairCloser(rules EditRules, r rune) bool {
+	pairs := []rune(rules.Pairs())
+	for i := 1; i < len(pairs); i += 2 {
+		if pairs[i] == r {
+			return true
+		}
+	}
+	return false
+}
+
+const brackets = "()[]{}"
+
+// matchBracket returns the index of the bracket matching the one at i in
+// rs, or -1. Brackets in quotes or escaped with a backslash are skipped.
+func matchBracket(rs []rune, i int) int {
+	if i < 0 || i >= len(rs) || !strings.ContainsRune(brackets, rs[i]) {
+		return -1
+	}
+	var open []int
+	var quote rune
+	for j := 0; j < len(rs) && (j <= i || len(open) > 0); j++ {
+		c := rs[j]
+		switch {
+		case c == '\\':
+			j++ // escaped rune
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			open = append(open, j)
+		case c == ')' || c == ']' || c == '}':
+			n := len(open)
+			if n == 0 || rs[open[n-1]] != openingBracket(c) {
+				if j == i {
+					return -1
+				}
+				continue
+			}
+			o := open[n-1]
+			open = open[:n-1]
+			if o == i {
+				return j
+			} else if j == i {
+				return o
+			}
+		}
+	}
+	return -1
+}
+
+func openingBracket(c rune) rune {
+	return rune(brackets[strings.IndexRune(brackets, c)-1])
+}
@@ -8,34 +8,24 @@ import (
 
 func main() {
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt:                 "> ",
-		HistoryFile:            "/tmp/readline-multiline",
-		DisableAutoSaveHistory: true,
+		Prompt:             "> ",
+		ContinuationPrompt: ">>> ",
+		HistoryFile:        "/tmp/readline-multiline",
+		// a statement is complete when it ends with ;
+		Validator: func(line []rune) bool {
+			return strings.HasSuffix(strings.TrimSpace(string(line)), ";")
+		},
 	})
 	if err != nil {
 		panic(err)
 	}
 	defer rl.Close()
 
-	var cmds []string
 	for {
 		line, err := rl.Readline()
 		if err != nil {
 			break
 		}
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			continue
-		}
-		cmds = append(cmds, line)
-		if !strings.HasSuffix(line, ";") {
-			rl.SetPrompt(">>> ")
-			continue
-		}
-		cmd := strings.Join(cmds, " ")
-		cmds = cmds[:0]
-		rl.SetPrompt("> ")
-		rl.SaveHistory(cmd)
-		println(cmd)
+		println(line)
 	}
 }
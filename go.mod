@@ -4,6 +4,6 @@ module github.com/wader/readline
 go 1.15
 
 require (
-	golang.org/x/sys v0.0.0-20220627191245-f75cf1eec38b
-	golang.org/x/text v0.3.7
+	golang.org/x/sys v0.5.0
+	golang.org/x/text v0.13.0
 )
@@ -0,0 +1,249 @@
+package readline
+
+import (
+	"unicode"
+)
+
+// Grapheme cluster boundaries following the extended grapheme cluster rules
+// of UAX #29, used so that cursor movement, deletion and width calculations
+// treat emoji sequences, flags and combining marks as one character.
+
+type graphemeProp int
+
+const (
+	gpOther graphemeProp = iota
+	gpCR
+	gpLF
+	gpControl
+	gpExtend
+	gpZWJ
+	gpRegionalIndicator
+	gpPrepend
+	gpSpacingMark
+	gpL
+	gpV
+	gpT
+	gpLV
+	gpLVT
+	gpExtPict
+)
+
+var graphemeExtend = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x200c, 0x200c, 1},
+		{0xff9e, 0xff9f, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1f3fb, 0x1f3ff, 1}, // emoji modifiers
+		{0xe0020, 0xe007f, 1}, // tags
+	},
+}
+
+var graphemePrepend = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x0600, 0x0605, 1},
+		{0x06dd, 0x06dd, 1},
+		{0x070f, 0x070f, 1},
+		{0x0890, 0x0891, 1},
+		{0x08e2, 0x08e2, 1},
+	},
+	R32: []unicode.Range32{
+		{0x110bd, 0x110bd, 1},
+		{0x110cd, 0x110cd, 1},
+	},
+}
+
+// extendedPictographic approximates the Extended_Pictographic property
+// from emoji-data.txt.
+var extendedPictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00a9, 0x00ae, 5},
+		{0x203c, 0x2049, 13},
+		{0x2122, 0x2139, 23},
+		{0x2194, 0x2199, 1},
+		{0x21a9, 0x21aa, 1},
+		{0x231a, 0x231b, 1},
+		{0x2328, 0x2388, 96},
+		{0x23cf, 0x23cf, 1},
+		{0x23e9, 0x23f3, 1},
+		{0x23f8, 0x23fa, 1},
+		{0x24c2, 0x24c2, 1},
+		{0x25aa, 0x25ab, 1},
+		{0x25b6, 0x25c0, 10},
+		{0x25fb, 0x25fe, 1},
+		{0x2600, 0x27bf, 1},
+		{0x2934, 0x2935, 1},
+		{0x2b05, 0x2b07, 1},
+		{0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b55, 5},
+		{0x3030, 0x303d, 13},
+		{0x3297, 0x3299, 2},
+	},
+	R32: []unicode.Range32{
+		{0x1f000, 0x1f0ff, 1},
+		{0x1f10d, 0x1f10f, 1},
+		{0x1f12f, 0x1f12f, 1},
+		{0x1f16c, 0x1f171, 1},
+		{0x1f17e, 0x1f17f, 1},
+		{0x1f18e, 0x1f18e, 1},
+		{0x1f191, 0x1f19a, 1},
+		{0x1f1ad, 0x1f1e5, 1},
+		{0x1f201, 0x1f20f, 1},
+		{0x1f21a, 0x1f22f, 21},
+		{0x1f232, 0x1f23a, 1},
+		{0x1f23c, 0x1f23f, 1},
+		{0x1f249, 0x1f3fa, 1},
+		{0x1f400, 0x1f53d, 1},
+		{0x1f546, 0x1f64f, 1},
+		{0x1f680, 0x1f6ff, 1},
+		{0x1f774, 0x1f77f, 1},
+		{0x1f7d5, 0x1f7ff, 1},
+		{0x1f80c, 0x1f80f, 1},
+		{0x1f848, 0x1f84f, 1},
+		{0x1f85a, 0x1f85f, 1},
+		{0x1f888, 0x1f88f, 1},
+		{0x1f8ae, 0x1f8ff, 1},
+		{0x1f90c, 0x1f93a, 1},
+		{0x1f93c, 0x1f945, 1},
+		{0x1f947, 0x1faff, 1},
+		{0x1fc00, 0x1fffd, 1},
+	},
+}
+
+func graphemePropOf(r rune) graphemeProp {
+	switch {
+	case r == '\r':
+		return gpCR
+	case r == '\n':
+		return gpLF
+	case r == 0x200d:
+		return gpZWJ
+	case r >= 0x1f1e6 && r <= 0x1f1ff:
+		return gpRegionalIndicator
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return gpLV
+		}
+		return gpLVT
+	case r >= 0x1100 && r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return gpL
+	case r >= 0x1160 && r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return gpV
+	case r >= 0x11a8 && r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return gpT
+	case unicode.In(r, graphemePrepend):
+		return gpPrepend
+	case unicode.In(r, unicode.Mn, unicode.Me, graphemeExtend):
+		return gpExtend
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return gpControl
+	case unicode.Is(unicode.Mc, r):
+		return gpSpacingMark
+	case unicode.Is(extendedPictographic, r):
+		return gpExtPict
+	}
+	return gpOther
+}
+
+// graphemeLen returns the number of runes in the grapheme cluster at the
+// start of rs.
+func graphemeLen(rs []rune) int {
+	if len(rs) == 0 {
+		return 0
+	}
+	prev := graphemePropOf(rs[0])
+	ri := 0                   // regional indicators in a row
+	pict := prev == gpExtPict // seen Extended_Pictographic Extend*
+	pictZWJ := false          // seen Extended_Pictographic Extend* ZWJ
+	if prev == gpRegionalIndicator {
+		ri = 1
+	}
+	for i := 1; i < len(rs); i++ {
+		p := graphemePropOf(rs[i])
+		join := false
+		switch {
+		case prev == gpCR && p == gpLF:
+			join = true
+		case prev == gpControl || prev == gpCR || prev == gpLF,
+			p == gpControl || p == gpCR || p == gpLF:
+		case prev == gpL && (p == gpL || p == gpV || p == gpLV || p == gpLVT),
+			(prev == gpLV || prev == gpV) && (p == gpV || p == gpT),
+			(prev == gpLVT || prev == gpT) && p == gpT:
+			join = true
+		case p == gpExtend || p == gpZWJ || p == gpSpacingMark:
+			join = true
+		case prev == gpPrepend:
+			join = true
+		case pictZWJ && p == gpExtPict:
+			join = true
+		case prev == gpRegionalIndicator && p == gpRegionalIndicator:
+			join = ri%2 == 1
+		}
+		if !join {
+			return i
+		}
+		switch {
+		case p == gpRegionalIndicator:
+			ri++
+		case p == gpExtPict:
+			pict, pictZWJ = true, false
+		case p == gpZWJ:
+			pictZWJ = pict
+			pict = false
+		case p != gpExtend:
+			pict, pictZWJ = false, false
+		}
+		prev = p
+	}
+	return len(rs)
+}
+
+// graphemeNext returns the index of the first cluster boundary after idx.
+func graphemeNext(rs []rune, idx int) int {
+	for i := 0; i < len(rs); {
+		i += graphemeLen(rs[i:])
+		if i > idx {
+			return i
+		}
+	}
+	return len(rs)
+}
+
+// graphemePrev returns the index of the last cluster boundary before idx.
+func graphemePrev(rs []rune, idx int) int {
+	prev := 0
+	for i := 0; i < idx && i < len(rs); {
+		prev = i
+		i += graphemeLen(rs[i:])
+	}
+	return prev
+}
+
+// graphemeWidth returns the number of cells the cluster c takes up.
+func graphemeWidth(c []rune) int {
+	if len(c) == 0 {
+		return 0
+	}
+	if len(c) > 1 && graphemePropOf(c[0]) == gpRegionalIndicator {
+		return 2 // flag
+	}
+	w := runes.Width(c[0])
+	for _, r := range c[1:] {
+		if r == 0xfe0f {
+			return 2 // emoji presentation selector
+		}
+	}
+	return w
+}
+
+// graphemeWidths returns the width of each rune in rs, the first rune of a
+// cluster has the width of the whole cluster and the others 0.
+func graphemeWidths(rs []rune) []int {
+	ws := make([]int, len(rs))
+	for i := 0; i < len(rs); {
+		n := graphemeLen(rs[i:])
+		ws[i] = graphemeWidth(rs[i : i+n])
+		i += n
+	}
+	return ws
+}
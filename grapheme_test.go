@@ -0,0 +1,45 @@
+package readline
+
+import (
+	"testing"
+)
+
+func TestGraphemeClusters(t *testing.T) {
+	tests := []struct {
+		s        string
+		clusters []string
+		width    int
+	}{
+		{"abc", []string{"a", "b", "c"}, 3},
+		{"e\u0301a", []string{"e\u0301", "a"}, 2},
+		{"\U0001F468‍\U0001F469‍\U0001F467!", []string{"\U0001F468‍\U0001F469‍\U0001F467", "!"}, 3},
+		{"\U0001F1F8\U0001F1EA\U0001F1FA\U0001F1F8\U0001F1EB", []string{"\U0001F1F8\U0001F1EA", "\U0001F1FA\U0001F1F8", "\U0001F1EB"}, 5},
+		{"\U0001F44D\U0001F3FD", []string{"\U0001F44D\U0001F3FD"}, 2},
+		{"❤️x", []string{"❤️", "x"}, 3},
+		{"각가", []string{"각", "가"}, 4},
+		{"\r\na", []string{"\r\n", "a"}, 1},
+		{"\U0001FAE0", []string{"\U0001FAE0"}, 2},
+	}
+	for _, test := range tests {
+		rs := []rune(test.s)
+		var clusters []string
+		for i := 0; i < len(rs); {
+			n := graphemeLen(rs[i:])
+			clusters = append(clusters, string(rs[i:i+n]))
+			i += n
+		}
+		testEqual(t, clusters, test.clusters, nil)
+		testEqual(t, runes.WidthAll(rs), test.width, nil)
+	}
+}
+
+func TestGraphemeNextPrev(t *testing.T) {
+	rs := []rune("a\U0001F44D\U0001F3FDb")
+	testEqual(t, graphemeNext(rs, 0), 1, nil)
+	testEqual(t, graphemeNext(rs, 1), 3, nil)
+	testEqual(t, graphemeNext(rs, 2), 3, nil)
+	testEqual(t, graphemeNext(rs, 4), 4, nil)
+	testEqual(t, graphemePrev(rs, 4), 3, nil)
+	testEqual(t, graphemePrev(rs, 3), 1, nil)
+	testEqual(t, graphemePrev(rs, 1), 0, nil)
+}
@@ -79,7 +79,7 @@ func (o *opHistory) historyUpdatePath(path string) {
 			break
 		}
 		// ignore the empty line
-		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(parseHistoryLine(line))
 		if len(line) == 0 {
 			continue
 		}
@@ -119,7 +119,7 @@ func (o *opHistory) rewriteLocked() {
 
 	buf := bufio.NewWriter(fd)
 	for elem := o.history.Front(); elem != nil; elem = elem.Next() {
-		buf.WriteString(string(elem.Value.(*hisItem).Source) + "\n")
+		buf.WriteString(historyLine(elem.Value.(*hisItem).Source))
 	}
 	buf.Flush()
 
@@ -225,6 +225,40 @@ func (o *opHistory) Next() ([]rune, bool) {
 	return runes.Copy(o.showItem(current.Value)), true
 }
 
+// First moves to the oldest history item, it returns false if already there.
+func (o *opHistory) First() ([]rune, bool) {
+	first := o.history.Front()
+	if o.current == nil || first == nil || first == o.current {
+		return nil, false
+	}
+	o.current = first
+	return runes.Copy(o.showItem(first.Value)), true
+}
+
+// Last moves to the line being edited, it returns false if already there.
+func (o *opHistory) Last() ([]rune, bool) {
+	last := o.history.Back()
+	if o.current == nil || last == nil || last == o.current {
+		return nil, false
+	}
+	o.current = last
+	return runes.Copy(o.showItem(last.Value)), true
+}
+
+// Suggest returns the rest of the most recent history item starting with
+// prefix, or nil if there is none.
+func (o *opHistory) Suggest(prefix []rune) []rune {
+	o.fdLock.Lock()
+	defer o.fdLock.Unlock()
+	for elem := o.history.Back(); elem != nil; elem = elem.Prev() {
+		item := elem.Value.(*hisItem)
+		if len(item.Source) > len(prefix) && runes.HasPrefix(item.Source, prefix) {
+			return runes.Copy(item.Source[len(prefix):])
+		}
+	}
+	return nil
+}
+
 // Disable the current history
 func (o *opHistory) Disable() {
 	o.enable = false
@@ -313,7 +347,7 @@ func (o *opHistory) Update(s []rune, commit bool) (err error) {
 		r.Source = s
 		if o.fd != nil {
 			// just report the error
-			_, err = o.fd.Write([]byte(string(r.Source) + "\n"))
+			_, err = o.fd.Write([]byte(historyLine(r.Source)))
 		}
 	} else {
 		r.Tmp = append(r.Tmp[:0], s...)
@@ -323,6 +357,35 @@ func (o *opHistory) Update(s []rune, commit bool) (err error) {
 	return
 }
 
+// historyMultiLine starts the line of a multi-line entry in the history
+// file. The byte is never part of valid UTF-8, so single line entries,
+// which are written as is, can't start with it.
+const historyMultiLine = "\xff"
+
+var (
+	historyEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	historyUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+// historyLine returns the history file line for s. Entries with newlines
+// are written after historyMultiLine with newlines and backslashes escaped
+// as \n and \\.
+func historyLine(s []rune) string {
+	str := string(s)
+	if !strings.Contains(str, "\n") {
+		return str + "\n"
+	}
+	return historyMultiLine + historyEscaper.Replace(str) + "\n"
+}
+
+// parseHistoryLine reverses historyLine for one line of the history file.
+func parseHistoryLine(line string) string {
+	if strings.HasPrefix(line, historyMultiLine) {
+		return historyUnescaper.Replace(strings.TrimRight(line[len(historyMultiLine):], "\r\n"))
+	}
+	return line
+}
+
 func (o *opHistory) Push(s []rune) {
 	s = runes.Copy(s)
 	elem := o.history.PushBack(&hisItem{Source: s})
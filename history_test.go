@@ -0,0 +1,59 @@
+package readline
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestHistoryFileRoundTrip(t *testing.T) {
+	f, err := ioutil.TempFile("", "readline-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	entries := []string{`cd C:\`, "ls", `a\\`, "x\\\ny\\", `echo foo \`, "p\\n\nq", "b"}
+	cfg := &Config{HistoryFile: f.Name(), HistoryLimit: 100}
+	h := newOpHistory(cfg)
+	h.Init()
+	for _, e := range entries {
+		h.New([]rune(e))
+	}
+	h.Close()
+
+	h = newOpHistory(cfg)
+	h.Init()
+	defer h.Close()
+	var got []string
+	for e := h.history.Front(); e != nil; e = e.Next() {
+		if s := string(e.Value.(*hisItem).Source); s != "" {
+			got = append(got, s)
+		}
+	}
+	testEqual(t, got, entries, nil)
+}
+
+func TestHistoryFileSingleLine(t *testing.T) {
+	// files with one entry per line read the same as before multi-line
+	// entries were supported
+	f, err := ioutil.TempFile("", "readline-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("echo a \\\nls\na\\\\\nx\\ny\n")
+	f.Close()
+
+	h := newOpHistory(&Config{HistoryFile: f.Name(), HistoryLimit: 100})
+	h.Init()
+	defer h.Close()
+	var got []string
+	for e := h.history.Front(); e != nil; e = e.Next() {
+		if s := string(e.Value.(*hisItem).Source); s != "" {
+			got = append(got, s)
+		}
+	}
+	testEqual(t, got, []string{`echo a \`, "ls", `a\\`, `x\ny`}, nil)
+}
@@ -0,0 +1,442 @@
+package readline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// InputrcFile returns the path of the users inputrc file, $INPUTRC if set
+// otherwise ~/.inputrc.
+func InputrcFile() string {
+	if p := os.Getenv("INPUTRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".inputrc")
+}
+
+// LoadInputrc reads the GNU readline inputrc file at path and applies it to
+// cfg, see ParseInputrc.
+func LoadInputrc(cfg *Config, path string) error {
+	return newInputrcParser(cfg).parseFile(path, 0)
+}
+
+// ParseInputrc reads GNU readline inputrc syntax from r and applies it to
+// cfg. Key bindings to the commands of Keymap, the $if, $else, $endif and
+// $include directives and the variables editing-mode, keymap,
+// keyseq-timeout, completion-ignore-case, search-ignore-case and
+// blink-matching-paren are supported. Macros, other variables and unknown
+// commands are ignored.
+//
+// $if matches mode=emacs, mode=vi, term=$TERM or the program name.
+func ParseInputrc(cfg *Config, r io.Reader) error {
+	return newInputrcParser(cfg).parse(r, "inputrc", "", 0)
+}
+
+// inputrc files can include each other, limit how deep
+const inputrcMaxInclude = 10
+
+type inputrcCond struct {
+	parent bool // enclosing block is active
+	cond   bool
+}
+
+type inputrcParser struct {
+	cfg    *Config
+	app    string
+	term   string
+	keymap string
+	conds  []inputrcCond
+}
+
+func newInputrcParser(cfg *Config) *inputrcParser {
+	if cfg.Keymap == nil {
+		cfg.Keymap = DefaultEmacsKeymap()
+	}
+	p := &inputrcParser{
+		cfg:  cfg,
+		app:  filepath.Base(os.Args[0]),
+		term: os.Getenv("TERM"),
+	}
+	p.setEditingMode(cfg.VimMode)
+	return p
+}
+
+func (p *inputrcParser) setEditingMode(vi bool) {
+	p.cfg.VimMode = vi
+	if vi {
+		p.keymap = "vi-insert"
+	} else {
+		p.keymap = "emacs"
+	}
+}
+
+func (p *inputrcParser) active() bool {
+	if len(p.conds) == 0 {
+		return true
+	}
+	c := p.conds[len(p.conds)-1]
+	return c.parent && c.cond
+}
+
+func (p *inputrcParser) parseFile(path string, depth int) error {
+	if depth > inputrcMaxInclude {
+		return fmt.Errorf("%s: too many nested includes", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.parse(f, path, filepath.Dir(path), depth)
+}
+
+func (p *inputrcParser) parse(r io.Reader, name, dir string, depth int) error {
+	conds := len(p.conds)
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var err error
+		if line[0] == '$' {
+			err = p.parseDirective(line[1:], dir, depth)
+		} else if !p.active() {
+			continue
+		} else if strings.HasPrefix(line, "set") && len(line) > 3 && unicode.IsSpace(rune(line[3])) {
+			p.parseSet(line[3:])
+		} else {
+			err = p.parseBinding(line)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, lineno, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if len(p.conds) != conds {
+		return fmt.Errorf("%s: missing $endif", name)
+	}
+	return nil
+}
+
+func (p *inputrcParser) parseDirective(line, dir string, depth int) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty directive")
+	}
+	arg := strings.TrimSpace(line[len(fields[0]):])
+	switch strings.ToLower(fields[0]) {
+	case "if":
+		p.conds = append(p.conds, inputrcCond{
+			parent: p.active(),
+			cond:   p.match(arg),
+		})
+	case "else":
+		if len(p.conds) == 0 {
+			return fmt.Errorf("$else without $if")
+		}
+		c := &p.conds[len(p.conds)-1]
+		c.cond = !c.cond
+	case "endif":
+		if len(p.conds) == 0 {
+			return fmt.Errorf("$endif without $if")
+		}
+		p.conds = p.conds[:len(p.conds)-1]
+	case "include":
+		if !p.active() {
+			return nil
+		}
+		path := arg
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		} else if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		return p.parseFile(path, depth+1)
+	default:
+		return fmt.Errorf("unknown directive $%s", fields[0])
+	}
+	return nil
+}
+
+// match evaluates the argument of $if
+func (p *inputrcParser) match(arg string) bool {
+	lower := strings.ToLower(arg)
+	switch {
+	case strings.HasPrefix(lower, "mode="):
+		if p.cfg.VimMode {
+			return lower[5:] == "vi"
+		}
+		return lower[5:] == "emacs"
+	case strings.HasPrefix(lower, "term="):
+		term := arg[5:]
+		short := p.term
+		if i := strings.IndexByte(short, '-'); i >= 0 {
+			short = short[:i]
+		}
+		return term == p.term || term == short
+	}
+	return strings.EqualFold(arg, p.app)
+}
+
+func inputrcBool(v string) bool {
+	return v == "" || strings.EqualFold(v, "on") || v == "1"
+}
+
+func (p *inputrcParser) parseSet(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	value := ""
+	if len(fields) > 1 {
+		value = fields[1]
+	}
+	switch strings.ToLower(fields[0]) {
+	case "editing-mode":
+		switch strings.ToLower(value) {
+		case "vi":
+			p.setEditingMode(true)
+		case "emacs":
+			p.setEditingMode(false)
+		}
+	case "keymap":
+		p.keymap = strings.ToLower(value)
+	case "keyseq-timeout":
+		// milliseconds, 0 or less waits indefinitely. A negative
+		// EscapeTimeout would also stop parsing sequences in vim mode.
+		if ms, err := strconv.Atoi(value); err == nil {
+			if ms <= 0 {
+				p.cfg.EscapeTimeout = math.MaxInt64
+			} else {
+				p.cfg.EscapeTimeout = time.Duration(ms) * time.Millisecond
+			}
+		}
+	case "completion-ignore-case", "search-ignore-case":
+		// only history search supports case folding
+		p.cfg.HistorySearchFold = inputrcBool(value)
+	case "blink-matching-paren":
+		p.cfg.EnableBlinkMatchingParen = inputrcBool(value)
+	}
+}
+
+// keymapPrefix returns the prefix of bindings in the current keymap and if
+// bindings in it apply to the Keymap of the config.
+func (p *inputrcParser) keymapPrefix() (string, bool) {
+	switch p.keymap {
+	case "emacs", "emacs-standard":
+		return "", !p.cfg.VimMode
+	case "emacs-meta":
+		return "\033", !p.cfg.VimMode
+	case "emacs-ctlx":
+		return "\030", !p.cfg.VimMode
+	case "vi-insert":
+		return "", p.cfg.VimMode
+	}
+	// vi command mode is not driven by the keymap
+	return "", false
+}
+
+func (p *inputrcParser) parseBinding(line string) error {
+	var seq []rune
+	var rest string
+	if line[0] == '"' || line[0] == '\'' {
+		var n int
+		var err error
+		seq, n, err = parseInputrcString(line)
+		if err != nil {
+			return err
+		}
+		rest = strings.TrimSpace(line[n:])
+		if !strings.HasPrefix(rest, ":") {
+			return fmt.Errorf("missing ':' after key sequence")
+		}
+		rest = rest[1:]
+	} else {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return fmt.Errorf("missing ':' after key name")
+		}
+		seq = parseInputrcKeyName(strings.TrimSpace(line[:i]))
+		rest = line[i+1:]
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return fmt.Errorf("missing command")
+	}
+	if rest[0] == '"' || rest[0] == '\'' {
+		// macros are not supported
+		return nil
+	}
+	cmd := strings.ToLower(strings.Fields(rest)[0])
+	if !keymapCommands[cmd] || len(seq) == 0 {
+		return nil
+	}
+	prefix, ok := p.keymapPrefix()
+	if !ok {
+		return nil
+	}
+	p.cfg.Keymap.Bind(prefix+string(seq), cmd)
+	return nil
+}
+
+var inputrcKeyNames = map[string]rune{
+	"del":     CharBackspace,
+	"rubout":  CharBackspace,
+	"esc":     CharEsc,
+	"escape":  CharEsc,
+	"lfd":     CharCtrlJ,
+	"newline": CharCtrlJ,
+	"ret":     CharEnter,
+	"return":  CharEnter,
+	"space":   ' ',
+	"spc":     ' ',
+	"tab":     CharTab,
+}
+
+func inputrcCtrl(r rune) rune {
+	if r == '?' {
+		return CharBackspace
+	}
+	return unicode.ToUpper(r) & 0x1f
+}
+
+// parseInputrcKeyName parses key names such as Control-u or Meta-Rubout
+func parseInputrcKeyName(s string) []rune {
+	ctrl, meta := false, false
+	for {
+		lower := strings.ToLower(s)
+		if strings.HasPrefix(lower, "control-") {
+			ctrl, s = true, s[8:]
+		} else if strings.HasPrefix(lower, "meta-") {
+			meta, s = true, s[5:]
+		} else if len(s) > 2 && (strings.HasPrefix(lower, "c-") || strings.HasPrefix(lower, "m-")) {
+			if lower[0] == 'c' {
+				ctrl = true
+			} else {
+				meta = true
+			}
+			s = s[2:]
+		} else {
+			break
+		}
+	}
+
+	r, ok := inputrcKeyNames[strings.ToLower(s)]
+	if !ok {
+		rs := []rune(s)
+		if len(rs) == 0 {
+			return nil
+		}
+		r = rs[0]
+	}
+	if ctrl {
+		r = inputrcCtrl(r)
+	}
+	if meta {
+		return []rune{CharEsc, r}
+	}
+	return []rune{r}
+}
+
+// parseInputrcString parses a quoted key sequence at the start of s and
+// returns the keys and the number of bytes consumed including quotes.
+func parseInputrcString(s string) ([]rune, int, error) {
+	rs := []rune(s)
+	quote := rs[0]
+	var out []rune
+	ctrl, meta := false, false
+	emit := func(r rune) {
+		if ctrl {
+			r = inputrcCtrl(r)
+		}
+		if meta {
+			out = append(out, CharEsc)
+		}
+		out = append(out, r)
+		ctrl, meta = false, false
+	}
+
+	for i := 1; i < len(rs); i++ {
+		r := rs[i]
+		if r == quote {
+			return out, len(string(rs[:i+1])), nil
+		}
+		if r != '\\' || i+1 >= len(rs) {
+			emit(r)
+			continue
+		}
+		i++
+		switch c := rs[i]; c {
+		case 'C', 'M':
+			if i+1 < len(rs) && rs[i+1] == '-' {
+				if c == 'C' {
+					ctrl = true
+				} else {
+					meta = true
+				}
+				i++
+				continue
+			}
+			emit(c)
+		case 'e':
+			emit(CharEsc)
+		case 'a':
+			emit(CharBell)
+		case 'b':
+			emit(CharCtrlH)
+		case 'd':
+			emit(CharBackspace)
+		case 'f':
+			emit('\f')
+		case 'n':
+			emit('\n')
+		case 'r':
+			emit('\r')
+		case 't':
+			emit('\t')
+		case 'v':
+			emit('\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(rs) && j < i+3 && rs[j] >= '0' && rs[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseInt(string(rs[i:j]), 8, 32)
+			emit(rune(n))
+			i = j - 1
+		case 'x':
+			j := i + 1
+			for j < len(rs) && j < i+3 && strings.ContainsRune("0123456789abcdefABCDEF", rs[j]) {
+				j++
+			}
+			if j == i+1 {
+				emit(c)
+				continue
+			}
+			n, _ := strconv.ParseInt(string(rs[i+1:j]), 16, 32)
+			emit(rune(n))
+			i = j - 1
+		default:
+			emit(c)
+		}
+	}
+	return nil, 0, fmt.Errorf("unterminated key sequence")
+}
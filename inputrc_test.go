@@ -0,0 +1,113 @@
+package readline
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInputrcString(t *testing.T) {
+	tests := []struct {
+		s   string
+		seq string
+	}{
+		{`"\C-x\C-r"`, "\030\022"},
+		{`"\M-."`, "\033."},
+		{`"\e[1;5D"`, "\033[1;5D"},
+		{`"\C-?"`, "\177"},
+		{`"\\\""`, `\"`},
+		{`"\101\x42"`, "AB"},
+		{`'\C-a'`, "\001"},
+	}
+	for _, test := range tests {
+		seq, n, err := parseInputrcString(test.s)
+		testEqual(t, string(seq), test.seq, nil)
+		testEqual(t, n, len(test.s), nil)
+		testEqual(t, err, nil, nil)
+	}
+
+	if _, _, err := parseInputrcString(`"\C-x`); err == nil {
+		t.Error("expected error for unterminated sequence")
+	}
+}
+
+func TestParseInputrcKeyName(t *testing.T) {
+	testEqual(t, string(parseInputrcKeyName("Control-u")), "\025", nil)
+	testEqual(t, string(parseInputrcKeyName("C-w")), "\027", nil)
+	testEqual(t, string(parseInputrcKeyName("Meta-Rubout")), "\033\177", nil)
+	testEqual(t, string(parseInputrcKeyName("TAB")), "\t", nil)
+	testEqual(t, string(parseInputrcKeyName("x")), "x", nil)
+}
+
+func TestParseInputrc(t *testing.T) {
+	cfg := &Config{}
+	err := ParseInputrc(cfg, strings.NewReader(`
+# comment
+set completion-ignore-case on
+"\C-x\C-u": undo
+Control-w: backward-kill-word
+"\C-xq": "macro"
+"\C-o": no-such-command
+$if mode=emacs
+Meta-Rubout: unix-word-rubout
+$else
+"\C-b": end-of-line
+$endif
+$if no-such-program
+"\C-a": end-of-line
+$endif
+set keymap emacs-ctlx
+"\C-e": beginning-of-line
+set editing-mode vi
+"\C-n": previous-history
+set keymap vi-command
+"\C-f": backward-char
+set keyseq-timeout 250
+`))
+	testEqual(t, err, nil, nil)
+	testEqual(t, cfg.HistorySearchFold, true, nil)
+	testEqual(t, cfg.EscapeTimeout, 250*time.Millisecond, nil)
+	testEqual(t, cfg.VimMode, true, nil)
+
+	k := cfg.Keymap
+	for seq, expected := range map[string]string{
+		"\030\025":            CmdUndo,
+		"\027":                CmdBackwardKillWord,
+		string(MetaBackspace): CmdUnixWordRubout,
+		"\002":                CmdBackwardChar,
+		"\001":                CmdBeginningOfLine,
+		"\030\005":            CmdBeginningOfLine,
+		"\006":                CmdForwardChar,
+		"\016":                CmdPreviousHistory,
+	} {
+		cmd, _ := k.Lookup(seq)
+		if cmd != expected {
+			t.Errorf("%q: got %q, expected %q", seq, cmd, expected)
+		}
+	}
+	_, ok := k.Lookup("\030q")
+	testEqual(t, ok, false, nil)
+	_, ok = k.Lookup("\017")
+	testEqual(t, ok, false, nil)
+}
+
+func TestParseInputrcErrors(t *testing.T) {
+	for _, s := range []string{
+		"$endif",
+		"$if mode=vi",
+		`"\C-x" undo`,
+		"$bogus",
+	} {
+		if err := ParseInputrc(&Config{}, strings.NewReader(s)); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestParseInputrcKeyseqTimeout(t *testing.T) {
+	// waiting indefinitely keeps escape sequences working in vi mode
+	cfg := &Config{}
+	err := ParseInputrc(cfg, strings.NewReader("set editing-mode vi\nset keyseq-timeout 0\n"))
+	testEqual(t, err, nil, nil)
+	testEqual(t, readTestLine(t, cfg, "ab\033[DX\r"), "aXb", nil)
+}
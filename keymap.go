@@ -0,0 +1,360 @@
+package readline
+
+import (
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Editing commands which can be bound to key sequences in a Keymap.
+// Names follow GNU readline where there is an equivalent command.
+const (
+	CmdAbort                = "abort"
+	CmdAcceptLine           = "accept-line"
+	CmdBackwardChar         = "backward-char"
+	CmdBackwardDeleteChar   = "backward-delete-char"
+	CmdBackwardKillWord     = "backward-kill-word"
+	CmdBackwardWord         = "backward-word"
+	CmdBeginningOfHistory   = "beginning-of-history"
+	CmdBeginningOfLine      = "beginning-of-line"
+	CmdBracketedPasteBegin  = "bracketed-paste-begin"
+	CmdClearScreen          = "clear-screen"
+	CmdComplete             = "complete"
+	CmdDeleteChar           = "delete-char"
+	CmdDigitArgument        = "digit-argument"
+	CmdEndOfFile            = "end-of-file"
+	CmdEndOfHistory         = "end-of-history"
+	CmdEndOfLine            = "end-of-line"
+	CmdForwardChar          = "forward-char"
+	CmdForwardSearchHistory = "forward-search-history"
+	CmdForwardWord          = "forward-word"
+	CmdInterrupt            = "interrupt"
+	CmdKillLine             = "kill-line"
+	CmdKillWord             = "kill-word"
+	CmdNextHistory          = "next-history"
+	CmdPreviousHistory      = "previous-history"
+	CmdReverseSearchHistory = "reverse-search-history"
+	CmdSelfInsert           = "self-insert"
+	CmdSuspend              = "suspend"
+	CmdTransposeChars       = "transpose-chars"
+	CmdUndo                 = "undo"
+	CmdUniversalArgument    = "universal-argument"
+	CmdUnixLineDiscard      = "unix-line-discard"
+	CmdUnixWordRubout       = "unix-word-rubout"
+	CmdYank                 = "yank"
+	CmdYankPop              = "yank-pop"
+)
+
+// keymapCommands is the set of commands Operation knows how to execute.
+var keymapCommands = map[string]bool{
+	CmdAbort:                true,
+	CmdAcceptLine:           true,
+	CmdBackwardChar:         true,
+	CmdBackwardDeleteChar:   true,
+	CmdBackwardKillWord:     true,
+	CmdBackwardWord:         true,
+	CmdBeginningOfHistory:   true,
+	CmdBeginningOfLine:      true,
+	CmdBracketedPasteBegin:  true,
+	CmdClearScreen:          true,
+	CmdComplete:             true,
+	CmdDeleteChar:           true,
+	CmdDigitArgument:        true,
+	CmdEndOfFile:            true,
+	CmdEndOfHistory:         true,
+	CmdEndOfLine:            true,
+	CmdForwardChar:          true,
+	CmdForwardSearchHistory: true,
+	CmdForwardWord:          true,
+	CmdInterrupt:            true,
+	CmdKillLine:             true,
+	CmdKillWord:             true,
+	CmdNextHistory:          true,
+	CmdPreviousHistory:      true,
+	CmdReverseSearchHistory: true,
+	CmdSelfInsert:           true,
+	CmdSuspend:              true,
+	CmdTransposeChars:       true,
+	CmdUndo:                 true,
+	CmdUniversalArgument:    true,
+	CmdUnixLineDiscard:      true,
+	CmdUnixWordRubout:       true,
+	CmdYank:                 true,
+	CmdYankPop:              true,
+}
+
+// Keymap maps key sequences to editing commands. A key sequence is the
+// string of runes read from the terminal, including virtual keys such as
+// MetaBackward, so Ctrl-W is bound with "\x17" or string(rune(CharCtrlW)).
+// Special keys such as KeyUp|ModShift are bound with KeySeq.
+//
+// Printable keys without a binding are inserted into the buffer, other
+// unbound keys are ignored.
+type Keymap struct {
+	m        sync.Mutex
+	bindings map[string]string
+	prefixes map[string]int // number of bindings each proper prefix starts
+}
+
+func NewKeymap() *Keymap {
+	return &Keymap{
+		bindings: make(map[string]string),
+		prefixes: make(map[string]int),
+	}
+}
+
+// DefaultEmacsKeymap returns a new keymap with the default emacs style
+// bindings, see doc/shortcut.md.
+func DefaultEmacsKeymap() *Keymap {
+	k := NewKeymap()
+	for _, b := range []struct {
+		key rune
+		cmd string
+	}{
+		{CharBell, CmdAbort},
+		{CharTab, CmdComplete},
+		{KeyCtrlI, CmdComplete},
+		{CharBckSearch, CmdReverseSearchHistory},
+		{CharFwdSearch, CmdForwardSearchHistory},
+		{CharCtrlU, CmdUnixLineDiscard},
+		{CharKill, CmdKillLine},
+		{CharTranspose, CmdTransposeChars},
+		{CharLineStart, CmdBeginningOfLine},
+		{CharLineEnd, CmdEndOfLine},
+		{CharBackspace, CmdBackwardDeleteChar},
+		{CharCtrlH, CmdBackwardDeleteChar},
+		{CharCtrlZ, CmdSuspend},
+		{CharCtrlL, CmdClearScreen},
+		{CharCtrlW, CmdUnixWordRubout},
+		{CharCtrlY, CmdYank},
+		{CharCtrl_, CmdUndo},
+		{CharEnter, CmdAcceptLine},
+		{KeyCtrlM, CmdAcceptLine},
+		{CharCtrlJ, CmdAcceptLine},
+		{CharBackward, CmdBackwardChar},
+		{CharForward, CmdForwardChar},
+		{CharPrev, CmdPreviousHistory},
+		{CharNext, CmdNextHistory},
+		{CharDelete, CmdDeleteChar},
+		{CharEOT, CmdEndOfFile},
+		{CharInterrupt, CmdInterrupt},
+		{MetaForward, CmdForwardWord},
+		{MetaBackward, CmdBackwardWord},
+		{MetaDelete, CmdKillWord},
+		{MetaBackspace, CmdBackwardKillWord},
+		{CharBracketedPasteStart, CmdBracketedPasteBegin},
+		{KeyLeft | ModCtrl, CmdBackwardWord},
+		{KeyLeft | ModAlt, CmdBackwardWord},
+		{KeyRight | ModCtrl, CmdForwardWord},
+		{KeyRight | ModAlt, CmdForwardWord},
+		{KeyDelete | ModCtrl, CmdKillWord},
+		{KeyPageUp, CmdBeginningOfHistory},
+		{KeyPageDown, CmdEndOfHistory},
+	} {
+		k.Bind(KeySeq(b.key), b.cmd)
+	}
+	k.Bind("\033y", CmdYankPop)
+	for _, r := range "0123456789-" {
+		k.Bind("\033"+string(r), CmdDigitArgument)
+	}
+	return k
+}
+
+// reverseCommands maps commands to the command doing the same in the
+// opposite direction, used for negative numeric arguments.
+var reverseCommands = map[string]string{
+	CmdForwardChar:        CmdBackwardChar,
+	CmdBackwardChar:       CmdForwardChar,
+	CmdForwardWord:        CmdBackwardWord,
+	CmdBackwardWord:       CmdForwardWord,
+	CmdDeleteChar:         CmdBackwardDeleteChar,
+	CmdBackwardDeleteChar: CmdDeleteChar,
+	CmdKillWord:           CmdBackwardKillWord,
+	CmdBackwardKillWord:   CmdKillWord,
+	CmdUnixWordRubout:     CmdKillWord,
+	CmdKillLine:           CmdUnixLineDiscard,
+	CmdNextHistory:        CmdPreviousHistory,
+	CmdPreviousHistory:    CmdNextHistory,
+}
+
+// keySeqMark starts a special key in a key sequence, it's never part of
+// valid UTF-8 so it can't collide with the encoding of other keys.
+const keySeqMark = 0xff
+
+// KeySeq returns the key sequence of keys as used by Keymap. It is the
+// same as string(keys) except for special keys such as KeyUp|ModShift,
+// which are outside of Unicode and encoded as keySeqMark followed by
+// their offset from KeyUp.
+func KeySeq(keys ...rune) string {
+	b := make([]byte, 0, len(keys))
+	for _, r := range keys {
+		if r > unicode.MaxRune {
+			o := r - KeyUp
+			b = append(b, keySeqMark, byte(o>>8), byte(o))
+			continue
+		}
+		b = append(b, string(r)...)
+	}
+	return string(b)
+}
+
+// keySeqRunes is the reverse of KeySeq.
+func keySeqRunes(seq string) []rune {
+	var rs []rune
+	for i := 0; i < len(seq); {
+		if seq[i] == keySeqMark && i+2 < len(seq) {
+			rs = append(rs, KeyUp+rune(seq[i+1])<<8+rune(seq[i+2]))
+			i += 3
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(seq[i:])
+		rs = append(rs, r)
+		i += n
+	}
+	return rs
+}
+
+// normalizeKeySeq translates Esc followed by a key and the escape
+// sequences of special keys into the virtual keys the terminal reports for
+// them, so "\x1bb" binds MetaBackward and "\x1b[1;5D" KeyLeft|ModCtrl.
+func normalizeKeySeq(seq string) string {
+	rs := keySeqRunes(seq)
+	out := make([]rune, 0, len(rs))
+	for i := 0; i < len(rs); i++ {
+		if rs[i] == CharEsc && i+1 < len(rs) {
+			if m, ok := metaKeys[rs[i+1]]; ok {
+				out = append(out, m)
+				i++
+				continue
+			}
+			if k, n := decodeKeySeq(rs[i+1:]); k != 0 {
+				out = append(out, k)
+				i += n
+				continue
+			}
+		}
+		out = append(out, rs[i])
+	}
+	return KeySeq(out...)
+}
+
+// decodeKeySeq decodes a CSI or SS3 sequence following Esc and returns the
+// key and the number of runes it used, or 0 if it is not a known key.
+func decodeKeySeq(rs []rune) (rune, int) {
+	if len(rs) < 2 || (rs[0] != CharEscapeEx && rs[0] != CharO) {
+		return 0, 0
+	}
+	i := 1
+	for i < len(rs) && (rs[i] == ';' || (rs[i] >= '0' && rs[i] <= '9')) {
+		i++
+	}
+	if i >= len(rs) {
+		return 0, 0
+	}
+	key := &escapeKeyPair{attr: string(rs[1:i]), typ: rs[i]}
+	var k rune
+	if rs[0] == CharEscapeEx {
+		k = escapeExKey(key)
+	} else {
+		k = escapeSS3Key(key)
+	}
+	if k == CharBracketedPasteStart {
+		return 0, 0
+	}
+	return k, i + 1
+}
+
+// Bind binds the key sequence seq to the editing command cmd, replacing
+// any previous binding.
+func (k *Keymap) Bind(seq string, cmd string) {
+	seq = normalizeKeySeq(seq)
+	if seq == "" {
+		return
+	}
+	k.m.Lock()
+	defer k.m.Unlock()
+	if _, ok := k.bindings[seq]; !ok {
+		k.addPrefixes(seq, 1)
+	}
+	k.bindings[seq] = cmd
+}
+
+// Unbind removes the binding of the key sequence seq.
+func (k *Keymap) Unbind(seq string) {
+	seq = normalizeKeySeq(seq)
+	k.m.Lock()
+	defer k.m.Unlock()
+	if _, ok := k.bindings[seq]; !ok {
+		return
+	}
+	delete(k.bindings, seq)
+	k.addPrefixes(seq, -1)
+}
+
+func (k *Keymap) addPrefixes(seq string, n int) {
+	rs := keySeqRunes(seq)
+	add := func(p string) {
+		k.prefixes[p] += n
+		if k.prefixes[p] <= 0 {
+			delete(k.prefixes, p)
+		}
+	}
+	for i := 0; i < len(rs); i++ {
+		if i > 0 {
+			add(KeySeq(rs[:i]...))
+		}
+		if isMetaKey(rs[i]) {
+			// a bare Esc followed by the key also reaches the binding
+			add(KeySeq(rs[:i]...) + "\033")
+		}
+	}
+}
+
+func isMetaKey(r rune) bool {
+	for _, m := range metaKeys {
+		if r == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the command bound to the key sequence seq.
+func (k *Keymap) Lookup(seq string) (cmd string, ok bool) {
+	seq = normalizeKeySeq(seq)
+	k.m.Lock()
+	defer k.m.Unlock()
+	cmd, ok = k.bindings[seq]
+	return
+}
+
+// isPrefix returns true if seq is the start of a longer bound sequence.
+func (k *Keymap) isPrefix(seq string) bool {
+	k.m.Lock()
+	defer k.m.Unlock()
+	return k.prefixes[seq] > 0
+}
+
+// Clone returns a copy of the keymap which can be changed independently.
+func (k *Keymap) Clone() *Keymap {
+	k.m.Lock()
+	defer k.m.Unlock()
+	n := NewKeymap()
+	for seq, cmd := range k.bindings {
+		n.bindings[seq] = cmd
+	}
+	for p, c := range k.prefixes {
+		n.prefixes[p] = c
+	}
+	return n
+}
+
+// isSelfInsert returns true if an unbound key should be inserted into the
+// buffer rather than ignored.
+func isSelfInsert(r rune) bool {
+	if r == CharBackspace || (r >= MetaBackward && r < virtualKeyEnd) ||
+		r > unicode.MaxRune {
+		return false
+	}
+	return IsPrintable(r)
+}
@@ -0,0 +1,64 @@
+package readline
+
+import (
+	"testing"
+)
+
+func TestKeymapBind(t *testing.T) {
+	k := DefaultEmacsKeymap()
+
+	cmd, ok := k.Lookup(string(rune(CharCtrlW)))
+	testEqual(t, cmd, CmdUnixWordRubout, nil)
+	testEqual(t, ok, true, nil)
+
+	// Esc followed by a key is stored as the virtual Meta key
+	k.Bind("\033b", CmdBeginningOfLine)
+	cmd, _ = k.Lookup(string(MetaBackward))
+	testEqual(t, cmd, CmdBeginningOfLine, nil)
+
+	k.Bind("\030\022", CmdUndo)
+	testEqual(t, k.isPrefix("\030"), true, nil)
+	k.Unbind("\030\022")
+	testEqual(t, k.isPrefix("\030"), false, nil)
+
+	c := k.Clone()
+	c.Unbind(string(rune(CharCtrlZ)))
+	_, ok = c.Lookup(string(rune(CharCtrlZ)))
+	testEqual(t, ok, false, nil)
+	_, ok = k.Lookup(string(rune(CharCtrlZ)))
+	testEqual(t, ok, true, nil)
+}
+
+func TestIsSelfInsert(t *testing.T) {
+	testEqual(t, isSelfInsert('a'), true, nil)
+	testEqual(t, isSelfInsert('你'), true, nil)
+	testEqual(t, isSelfInsert(CharCtrlZ), false, nil)
+	testEqual(t, isSelfInsert(CharBackspace), false, nil)
+	testEqual(t, isSelfInsert(MetaShiftTab), false, nil)
+}
+
+func TestKeymapEscapeSequences(t *testing.T) {
+	k := NewKeymap()
+	k.Bind("\033[1;5D", CmdBackwardWord)
+	k.Bind("\033[6~", CmdEndOfHistory)
+	k.Bind("\033OP", CmdUndo)
+	k.Bind("\033[A", CmdPreviousHistory)
+
+	for _, test := range []struct {
+		key rune
+		cmd string
+	}{
+		{KeyLeft | ModCtrl, CmdBackwardWord},
+		{KeyPageDown, CmdEndOfHistory},
+		{KeyF1, CmdUndo},
+		{CharPrev, CmdPreviousHistory},
+	} {
+		cmd, _ := k.Lookup(KeySeq(test.key))
+		testEqual(t, cmd, test.cmd, nil)
+	}
+	testEqual(t, isSelfInsert(KeyF12|ModShift|ModCtrl), false, nil)
+	testEqual(t, isSelfInsert('\uea60'), true, nil)
+
+	seq := []rune{CharEsc, KeyUp, KeyF12 | ModShift | ModAlt | ModCtrl, 'ä'}
+	testEqual(t, keySeqRunes(KeySeq(seq...)), seq, nil)
+}
@@ -0,0 +1,51 @@
+package readline
+
+// LiveLine is a line drawn between the output and the prompt which can be
+// changed while a line is read, for example a progress bar or a spinner.
+// Live lines are only shown while reading a line and are removed from
+// above a finished line. Changes are drawn together with the edited line,
+// like PrintAbove they are collected for a moment so frequent updates are
+// cheap. It's safe to use from any goroutine.
+type LiveLine struct {
+	o    *Operation
+	text string
+}
+
+// NewLiveLine adds a live line below the ones already added.
+func (o *Operation) NewLiveLine(text string) *LiveLine {
+	l := &LiveLine{o: o, text: text}
+	o.printm.Lock()
+	defer o.printm.Unlock()
+	o.live = append(o.live, l)
+	o.liveChanged = true
+	o.schedulePrint()
+	return l
+}
+
+// Set changes the text of the line, it's cut to the width of the terminal.
+func (l *LiveLine) Set(text string) {
+	o := l.o
+	o.printm.Lock()
+	defer o.printm.Unlock()
+	if l.text == text {
+		return
+	}
+	l.text = text
+	o.liveChanged = true
+	o.schedulePrint()
+}
+
+// Remove removes the line, the lines below it move up.
+func (l *LiveLine) Remove() {
+	o := l.o
+	o.printm.Lock()
+	defer o.printm.Unlock()
+	for i, e := range o.live {
+		if e == l {
+			o.live = append(o.live[:i], o.live[i+1:]...)
+			o.liveChanged = true
+			o.schedulePrint()
+			return
+		}
+	}
+}
@@ -1,15 +1,24 @@
 package readline
 
 import (
+	"context"
 	"errors"
 	"io"
+	"strings"
 	"sync"
+	"time"
 )
 
 var (
 	ErrInterrupt = errors.New("Interrupt")
 )
 
+// how long Config.EnableBlinkMatchingParen highlights a bracket
+var blinkMatchingParenTime = 500 * time.Millisecond
+
+// how long PrintAbove and LiveLine collect changes before redrawing
+var printAboveInterval = 20 * time.Millisecond
+
 type InterruptError struct {
 	Line []rune
 }
@@ -27,8 +36,22 @@ type Operation struct {
 	errchan chan error
 	w       io.Writer
 
+	cancelchan chan struct{} // aborts the current line, see RunesContext
+	canceled   chan struct{} // the line has been aborted
+	cancelRead bool          // a read was aborted by cancelchan, see readRune
+
 	isPrompting bool // true when prompt written and waiting for input
 
+	pending []rune // keys read ahead by readCommand but not yet handled
+
+	// lines queued by PrintAbove, the live lines and if they changed since
+	// drawn, written by flushPrint when printTimer fires
+	printm      sync.Mutex
+	printBuf    []byte
+	live        []*LiveLine
+	liveChanged bool
+	printTimer  bool
+
 	history *opHistory
 	*opSearch
 	*opCompleter
@@ -53,6 +76,7 @@ func (w *wrapWriter) Write(b []byte) (int, error) {
 func (o *Operation) write(target io.Writer, b []byte) (int, error) {
 	o.m.Lock()
 	defer o.m.Unlock()
+	o.flushPrint()
 
 	if !o.isPrompting {
 		return target.Write(b)
@@ -84,21 +108,108 @@ func (o *Operation) write(target io.Writer, b []byte) (int, error) {
 	return n, err
 }
 
+// PrintAbove prints s above the line being edited, a newline is added if
+// s doesn't end with one. Lines printed in quick succession are written
+// together with one redraw, so it can be used for frequent output from
+// many goroutines. Without a prompt on screen s is written right away.
+func (o *Operation) PrintAbove(s string) {
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	o.m.Lock()
+	defer o.m.Unlock()
+	if !o.isPrompting {
+		// keep the order with other output, there is nothing to redraw
+		o.flushPrint()
+		o.cfg.Stdout.Write([]byte(s))
+		return
+	}
+	o.printm.Lock()
+	defer o.printm.Unlock()
+	o.printBuf = append(o.printBuf, s...)
+	o.schedulePrint()
+}
+
+// schedulePrint schedules flushPrint if it isn't already, o.printm must
+// be held.
+func (o *Operation) schedulePrint() {
+	if o.printTimer {
+		return
+	}
+	o.printTimer = true
+	time.AfterFunc(printAboveInterval, func() {
+		o.m.Lock()
+		defer o.m.Unlock()
+		o.flushPrint()
+	})
+}
+
+// flushPrint writes the lines queued by PrintAbove and redraws changed
+// live lines, o.m must be held.
+func (o *Operation) flushPrint() {
+	o.printm.Lock()
+	b := o.printBuf
+	var live []string
+	changed := o.liveChanged
+	for _, l := range o.live {
+		live = append(live, l.text)
+	}
+	o.printBuf, o.liveChanged, o.printTimer = nil, false, false
+	o.printm.Unlock()
+	if len(b) == 0 && !changed {
+		return
+	}
+
+	if !o.isPrompting {
+		if len(b) > 0 {
+			o.cfg.Stdout.Write(b)
+		}
+		o.buf.SetLive(live)
+		return
+	}
+	o.buf.Refresh(func() {
+		if len(b) > 0 {
+			if o.buf.ppos > 0 {
+				// keep the lines apart from output not ending with a newline
+				o.cfg.Stdout.Write([]byte("\n"))
+			}
+			o.cfg.Stdout.Write(b)
+			o.buf.ppos = 0
+		}
+		o.buf.live = live
+	})
+	if o.IsSearchMode() {
+		o.SearchRefresh(-1)
+	}
+	if o.IsInCompleteMode() {
+		o.CompleteRefresh()
+	}
+}
+
 func NewOperation(t *Terminal, cfg *Config) *Operation {
 	op := &Operation{
 		t:       t,
 		buf:     NewRuneBuffer(t, cfg.Prompt, cfg),
 		outchan: make(chan []rune),
 		errchan: make(chan error, 1),
+
+		cancelchan: make(chan struct{}),
+		canceled:   make(chan struct{}),
 	}
 	op.w = op.buf.w
 	op.SetConfig(cfg)
 	op.opVim = newVimMode(op)
 	op.opCompleter = newOpCompleter(op.buf.w, op)
 	op.opPassword = newOpPassword(op)
-	op.cfg.FuncOnWidthChanged(t.OnSizeChange)
+	op.cfg.FuncOnWidthChanged(func() {
+		t.OnSizeChange()
+		op.onSizeChange()
+	})
 	op.opUndo = newOpUndo(op)
 	op.buf.OnChange = op.opUndo.add
+	op.buf.HistorySuggest = func(line []rune) []rune {
+		return op.history.Suggest(line)
+	}
 	go op.ioloop()
 	return op
 }
@@ -107,6 +218,10 @@ func (o *Operation) SetPrompt(s string) {
 	o.buf.SetPrompt(s)
 }
 
+func (o *Operation) SetRightPrompt(s string) {
+	o.buf.SetRightPrompt(s)
+}
+
 func (o *Operation) SetMaskRune(r rune) {
 	o.buf.SetMask(r)
 }
@@ -118,11 +233,195 @@ func (o *Operation) GetConfig() *Config {
 	return &cfg
 }
 
+// readRune returns the next key, either one pushed back by readCommand or
+// a new one from the terminal. When the line is aborted by RunesContext 0
+// is returned and cancelRead is set until the ioloop handles it.
+func (o *Operation) readRune() rune {
+	if len(o.pending) > 0 {
+		r := o.pending[0]
+		o.pending = o.pending[1:]
+		return r
+	}
+	if o.cancelRead {
+		return 0
+	}
+	select {
+	case r, ok := <-o.t.outchan:
+		if !ok {
+			return 0
+		}
+		return r
+	case <-o.cancelchan:
+		o.cancelRead = true
+		return 0
+	}
+}
+
+// readNextRune is readRune for reads in the middle of a command, 0 is
+// returned on EOF and cancel which are left to be handled by the ioloop.
+func (o *Operation) readNextRune() rune {
+	r := o.readRune()
+	if r == 0 && !o.cancelRead {
+		o.pending = append(o.pending, r)
+		return 0
+	}
+	return r
+}
+
+// cancel aborts the current line, it's erased and the buffer, history
+// position and modes are reset for the next line.
+func (o *Operation) cancel() {
+	if o.IsSearchMode() {
+		o.ExitSearchMode(true)
+	}
+	if o.IsInCompleteMode() {
+		o.ExitCompleteMode(true)
+	}
+	o.buf.Clean()
+	o.buf.Reset()
+	o.history.Revert()
+	o.opUndo.init()
+	o.pending = nil
+	o.cancelRead = false
+	o.canceled <- struct{}{}
+}
+
+// readCommand looks up the command bound to the key sequence starting with
+// r in the keymap, reading more keys as long as the sequence is the prefix
+// of a longer binding. It returns the command, or "" if the sequence is
+// not bound, and the last key of the sequence.
+func (o *Operation) readCommand(r rune) (string, rune) {
+	km := o.GetConfig().Keymap
+	if r == KeyCtrlBracket && !km.isPrefix(KeySeq(r)) {
+		if _, ok := km.Lookup(KeySeq(r)); !ok {
+			// unbound it's Esc, the start of Meta keys
+			r = CharEsc
+		}
+	}
+	seq := []rune{r}
+	for km.isPrefix(KeySeq(seq...)) {
+		switch seq[len(seq)-1] {
+		case CharInterrupt, CharEnter, KeyCtrlM, CharCtrlJ, CharDelete, CharEOT:
+			// terminal waits to be kicked after these keys
+			o.t.KickRead()
+		}
+		next := o.readNextRune()
+		if next == 0 {
+			break
+		}
+		if _, ok := km.Lookup(KeySeq(seq...)); ok {
+			// seq is bound by itself, only continue if next extends it
+			ext := KeySeq(append(seq, next)...)
+			if _, ok := km.Lookup(ext); !ok && !km.isPrefix(ext) {
+				o.pending = append(o.pending, next)
+				break
+			}
+		}
+		seq = append(seq, next)
+	}
+
+	last := seq[len(seq)-1]
+	if cmd, ok := km.Lookup(KeySeq(seq...)); ok {
+		return cmd, last
+	}
+	if len(seq) > 1 {
+		o.t.Bell()
+		return "", last
+	}
+	if isSelfInsert(r) {
+		return CmdSelfInsert, r
+	}
+	return "", r
+}
+
+// lineCommands move or delete in the line, they are repeated at most once
+// more than the line is long.
+var lineCommands = map[string]bool{
+	CmdBackwardChar:       true,
+	CmdForwardChar:        true,
+	CmdBackwardWord:       true,
+	CmdForwardWord:        true,
+	CmdKillWord:           true,
+	CmdBackwardDeleteChar: true,
+	CmdBackwardKillWord:   true,
+	CmdUnixWordRubout:     true,
+	CmdDeleteChar:         true,
+}
+
+// maxArgument is the largest numeric argument, larger ones are reduced to
+// it like in GNU readline.
+const maxArgument = 1000000
+
+// readArgument reads the rest of a numeric argument started by a
+// digit-argument or universal-argument command. It returns the argument,
+// at most maxArgument, and the command following it which the argument
+// applies to.
+func (o *Operation) readArgument(cmd string, r rune) (int, string, rune) {
+	n, sign, digits := 1, 1, false
+	for {
+		isDigitKey := cmd == CmdDigitArgument || cmd == CmdSelfInsert
+		switch {
+		case cmd == CmdUniversalArgument && !digits:
+			n *= 4
+		case isDigitKey && r >= '0' && r <= '9':
+			if !digits {
+				n, digits = 0, true
+			}
+			n = n*10 + int(r-'0')
+		case isDigitKey && r == '-' && !digits:
+			n, sign = 1, -1
+		default:
+			return sign * n, cmd, r
+		}
+		if n > maxArgument {
+			n = maxArgument
+		}
+
+		r = o.readNextRune()
+		if r == 0 {
+			return sign * n, "", r
+		}
+		cmd, r = o.readCommand(r)
+	}
+}
+
+// readPaste reads bracketed pasted text up to CharBracketedPasteEnd and
+// prepares it for insertion according to the config.
+func (o *Operation) readPaste() []rune {
+	var text []rune
+	for {
+		r := o.readNextRune()
+		if r == 0 {
+			break
+		}
+		if r == CharBracketedPasteEnd {
+			break
+		}
+		text = append(text, r)
+	}
+
+	cfg := o.GetConfig()
+	s := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(text))
+	if !cfg.BracketedPasteNewlines {
+		s = strings.Replace(s, "\n", " ", -1)
+	}
+	text = []rune(s)
+	if cfg.FuncFilterPaste != nil {
+		text = cfg.FuncFilterPaste(text)
+	}
+	return text
+}
+
 func (o *Operation) ioloop() {
 	for {
 		keepInSearchMode := false
 		keepInCompleteMode := false
-		r := o.t.ReadRune()
+		isFlush := false
+		r := o.readRune()
+		if o.cancelRead {
+			o.cancel()
+			continue
+		}
 
 		if o.GetConfig().FuncFilterInputRune != nil {
 			var process bool
@@ -146,11 +445,13 @@ func (o *Operation) ioloop() {
 				// let's flush them by sending CharEnter.
 				// And we will got io.EOF int next loop.
 				r = CharEnter
+				isFlush = true
 			}
 		}
 		isUpdateHistory := true
 
 		if o.IsInPagerMode() {
+			r = legacyKey(r)
 			keepInCompleteMode = o.HandlePagerMode(r)
 			if r == CharEnter || r == CharCtrlJ || r == CharInterrupt {
 				o.t.KickRead()
@@ -162,6 +463,7 @@ func (o *Operation) ioloop() {
 		}
 
 		if o.IsInCompleteSelectMode() {
+			r = legacyKey(r)
 			keepInCompleteMode = o.HandleCompleteSelect(r)
 			if keepInCompleteMode {
 				continue
@@ -180,15 +482,43 @@ func (o *Operation) ioloop() {
 			}
 		}
 
+		o.buf.StartCommand()
 		if o.IsEnableVimMode() {
-			r = o.HandleVim(r, o.t.ReadRune)
+			r = o.HandleVim(r, o.readNextRune)
 			if r == 0 {
 				continue
 			}
 		}
 
-		switch r {
-		case CharBell:
+		cmd := CmdAcceptLine
+		if !isFlush {
+			cmd, r = o.readCommand(r)
+		}
+		count := 1
+		if cmd == CmdDigitArgument || cmd == CmdUniversalArgument {
+			count, cmd, r = o.readArgument(cmd, r)
+		}
+		if count < 0 {
+			if rev, ok := reverseCommands[cmd]; ok {
+				cmd = rev
+			}
+			count = -count
+		}
+		if lineCommands[cmd] && count > o.buf.ShownLen()+1 {
+			// repeating more often can't change the line
+			count = o.buf.ShownLen() + 1
+		}
+		if n := o.buf.Len() + 1; cmd == CmdTransposeChars && count > n {
+			// at the end of the line the last two characters are swapped
+			// back and forth
+			count = n + (count-n)%2
+		}
+		if n := len(o.opUndo.stack); cmd == CmdUndo && count > n {
+			count = n
+		}
+
+		switch cmd {
+		case CmdAbort:
 			if o.IsSearchMode() {
 				o.ExitSearchMode(true)
 				o.buf.Refresh(nil)
@@ -197,7 +527,7 @@ func (o *Operation) ioloop() {
 				o.ExitCompleteMode(true)
 				o.buf.Refresh(nil)
 			}
-		case CharTab:
+		case CmdComplete:
 			if o.GetConfig().AutoComplete == nil {
 				o.t.Bell()
 				break
@@ -211,36 +541,48 @@ func (o *Operation) ioloop() {
 				o.t.Bell()
 			}
 			o.buf.Refresh(nil)
-		case CharBckSearch:
+		case CmdReverseSearchHistory:
 			if !o.SearchMode(S_DIR_BCK) {
 				o.t.Bell()
 				break
 			}
 			keepInSearchMode = true
-		case CharCtrlU:
+		case CmdUnixLineDiscard:
 			o.buf.KillFront()
-		case CharFwdSearch:
+		case CmdForwardSearchHistory:
 			if !o.SearchMode(S_DIR_FWD) {
 				o.t.Bell()
 				break
 			}
 			keepInSearchMode = true
-		case CharKill:
+		case CmdKillLine:
 			o.buf.Kill()
 			keepInCompleteMode = true
-		case MetaForward:
-			o.buf.MoveToNextWord()
-		case CharTranspose:
-			o.buf.Transpose()
-		case MetaBackward:
-			o.buf.MoveToPrevWord()
-		case MetaDelete:
-			o.buf.DeleteWord()
-		case CharLineStart:
+		case CmdForwardWord:
+			for i := 0; i < count; i++ {
+				if !o.buf.AcceptSuggestion(true) {
+					o.buf.MoveToNextWord()
+				}
+			}
+		case CmdTransposeChars:
+			for i := 0; i < count; i++ {
+				o.buf.Transpose()
+			}
+		case CmdBackwardWord:
+			for i := 0; i < count; i++ {
+				o.buf.MoveToPrevWord()
+			}
+		case CmdKillWord:
+			for i := 0; i < count; i++ {
+				o.buf.DeleteWord()
+			}
+		case CmdBeginningOfLine:
 			o.buf.MoveToLineStart()
-		case CharLineEnd:
-			o.buf.MoveToLineEnd()
-		case CharBackspace, CharCtrlH:
+		case CmdEndOfLine:
+			if !o.buf.AcceptSuggestion(false) {
+				o.buf.MoveToLineEnd()
+			}
+		case CmdBackwardDeleteChar:
 			if o.IsSearchMode() {
 				o.SearchBackspace()
 				keepInSearchMode = true
@@ -251,22 +593,32 @@ func (o *Operation) ioloop() {
 				o.t.Bell()
 				break
 			}
-			o.buf.Backspace()
-		case CharCtrlZ:
+			for i := 0; i < count; i++ {
+				o.buf.Backspace()
+			}
+		case CmdSuspend:
 			o.buf.Clean()
 			o.t.SleepToResume()
 			o.Refresh()
-		case CharCtrlL:
+		case CmdClearScreen:
 			ClearScreen(o.w)
 			o.buf.SetOffset("1;1")
 			o.Refresh()
-		case MetaBackspace, CharCtrlW:
-			o.buf.BackEscapeWord()
-		case CharCtrlY:
+		case CmdBackwardKillWord, CmdUnixWordRubout:
+			for i := 0; i < count; i++ {
+				o.buf.BackEscapeWord()
+			}
+		case CmdYank:
 			o.buf.Yank()
-		case CharCtrl_:
-			o.opUndo.undo()
-		case CharEnter, CharCtrlJ:
+		case CmdYankPop:
+			if !o.buf.YankPop() {
+				o.t.Bell()
+			}
+		case CmdUndo:
+			for i := 0; i < count; i++ {
+				o.opUndo.undo()
+			}
+		case CmdAcceptLine:
 			if o.IsSearchMode() {
 				o.ExitSearchMode(false)
 			}
@@ -274,10 +626,20 @@ func (o *Operation) ioloop() {
 				o.ExitCompleteMode(true)
 				o.buf.Refresh(nil)
 			}
+			// at EOF the incomplete line is returned as is, there is no
+			// more input to complete it with
+			if v := o.GetConfig().Validator; v != nil && !isFlush && !v(o.buf.Runes()) {
+				o.t.KickRead()
+				o.buf.NewLine()
+				break
+			}
 			o.buf.MoveToLineEnd()
 			var data []rune
 			if !o.GetConfig().UniqueEditLine {
-				o.buf.WriteRune('\n')
+				if prompt, ok := o.transientPrompt(); ok {
+					o.buf.Transient(prompt)
+				}
+				o.buf.Finish("")
 				data = o.buf.Reset()
 				data = data[:len(data)-1] // trim \n
 			} else {
@@ -292,45 +654,98 @@ func (o *Operation) ioloop() {
 				isUpdateHistory = false
 			}
 			o.opUndo.init()
-		case CharBackward:
-			o.buf.MoveBackward()
-		case CharForward:
-			o.buf.MoveForward()
-		case CharPrev:
-			buf := o.history.Prev()
+		case CmdBackwardChar:
+			for i := 0; i < count; i++ {
+				o.buf.MoveBackward()
+			}
+		case CmdForwardChar:
+			for i := 0; i < count; i++ {
+				if !o.buf.AcceptSuggestion(false) {
+					o.buf.MoveForward()
+				}
+			}
+		case CmdPreviousHistory:
+			for count > 0 && o.buf.MoveLineUp() {
+				count--
+			}
+			if count == 0 {
+				break
+			}
+			var buf []rune
+			for i := 0; i < count; i++ {
+				prev := o.history.Prev()
+				if prev == nil {
+					break
+				}
+				buf = prev
+			}
 			if buf != nil {
 				o.buf.Set(buf)
 				o.opUndo.init()
 			} else {
 				o.t.Bell()
 			}
-		case CharNext:
-			buf, ok := o.history.Next()
+		case CmdBeginningOfHistory, CmdEndOfHistory:
+			var buf []rune
+			var ok bool
+			if cmd == CmdBeginningOfHistory {
+				buf, ok = o.history.First()
+			} else {
+				buf, ok = o.history.Last()
+			}
 			if ok {
 				o.buf.Set(buf)
 				o.opUndo.init()
 			} else {
 				o.t.Bell()
 			}
-		case CharDelete:
+		case CmdNextHistory:
+			for count > 0 && o.buf.MoveLineDown() {
+				count--
+			}
+			if count == 0 {
+				break
+			}
+			var buf []rune
+			found := false
+			for i := 0; i < count; i++ {
+				next, ok := o.history.Next()
+				if !ok {
+					break
+				}
+				buf, found = next, true
+			}
+			if found {
+				o.buf.Set(buf)
+				o.opUndo.init()
+			} else {
+				o.t.Bell()
+			}
+		case CmdDeleteChar:
 			o.t.KickRead()
 			if o.buf.Len() > 0 || !o.IsNormalMode() {
-				if !o.buf.Delete() {
-					o.t.Bell()
+				for i := 0; i < count; i++ {
+					if !o.buf.Delete() {
+						o.t.Bell()
+						break
+					}
 				}
 			}
-		case CharEOT:
+		case CmdEndOfFile:
 			if o.buf.Len() > 0 || !o.IsNormalMode() {
 				o.t.KickRead()
-				if !o.buf.Delete() {
-					o.t.Bell()
+				for i := 0; i < count; i++ {
+					if !o.buf.Delete() {
+						o.t.Bell()
+						break
+					}
 				}
 				break
 			}
 
 			// treat as EOF
 			if !o.GetConfig().UniqueEditLine {
-				o.buf.WriteString(o.GetConfig().EOFPrompt + "\n")
+				o.buf.Finish(o.GetConfig().EOFPrompt)
 			}
 			o.buf.Reset()
 			isUpdateHistory = false
@@ -339,7 +754,7 @@ func (o *Operation) ioloop() {
 			if o.GetConfig().UniqueEditLine {
 				o.buf.Clean()
 			}
-		case CharInterrupt:
+		case CmdInterrupt:
 			if o.IsSearchMode() {
 				o.t.KickRead()
 				o.ExitSearchMode(true)
@@ -355,7 +770,7 @@ func (o *Operation) ioloop() {
 			o.buf.Refresh(nil)
 			hint := o.GetConfig().InterruptPrompt + "\n"
 			if !o.GetConfig().UniqueEditLine {
-				o.buf.WriteString(hint)
+				o.buf.Finish(o.GetConfig().InterruptPrompt)
 			}
 			remain := o.buf.Reset()
 			if !o.GetConfig().UniqueEditLine {
@@ -364,15 +779,32 @@ func (o *Operation) ioloop() {
 			isUpdateHistory = false
 			o.history.Revert()
 			o.errchan <- &InterruptError{remain}
-		case MetaShiftTab:
-			// do nothing
-		default:
+		case CmdBracketedPasteBegin:
+			text := o.readPaste()
+			if o.IsSearchMode() {
+				for _, c := range text {
+					o.SearchChar(c)
+				}
+				keepInSearchMode = true
+				break
+			}
+			if len(text) > 0 {
+				o.buf.WriteRunes(text)
+			}
+		case CmdSelfInsert:
 			if o.IsSearchMode() {
 				o.SearchChar(r)
 				keepInSearchMode = true
 				break
 			}
-			o.buf.WriteRune(r)
+			if count > 1 {
+				o.buf.WriteString(strings.Repeat(string(r), count))
+			} else {
+				o.buf.Type(r)
+			}
+			if o.GetConfig().EnableBlinkMatchingParen {
+				o.blinkMatchingParen()
+			}
 			if o.IsInCompleteMode() {
 				o.OnComplete()
 				if o.IsInCompleteMode() {
@@ -427,7 +859,18 @@ func (o *Operation) String() (string, error) {
 	return string(r), err
 }
 
+func (o *Operation) StringContext(ctx context.Context) (string, error) {
+	r, err := o.RunesContext(ctx)
+	return string(r), err
+}
+
 func (o *Operation) Runes() ([]rune, error) {
+	return o.RunesContext(context.Background())
+}
+
+// RunesContext is like Runes but aborts reading the line when ctx is done,
+// the line is erased and ctx.Err() is returned.
+func (o *Operation) RunesContext(ctx context.Context) ([]rune, error) {
 	o.t.EnterRawMode()
 	defer o.t.ExitRawMode()
 
@@ -459,17 +902,81 @@ func (o *Operation) Runes() ([]rune, error) {
 		o.m.Unlock()
 	}()
 
+	if cfg := o.GetConfig(); cfg.PromptFunc != nil && cfg.PromptRefreshInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go o.promptTicker(cfg.PromptRefreshInterval, done)
+	}
+
+	select {
+	case r := <-o.outchan:
+		return r, nil
+	case err := <-o.errchan:
+		return lineError(err)
+	case <-ctx.Done():
+	}
+
+	// the line might be finished while canceling, either the ioloop
+	// takes the cancel or it has a result
 	select {
+	case o.cancelchan <- struct{}{}:
+		<-o.canceled
+		return nil, ctx.Err()
 	case r := <-o.outchan:
 		return r, nil
 	case err := <-o.errchan:
-		if e, ok := err.(*InterruptError); ok {
-			return e.Line, ErrInterrupt
+		return lineError(err)
+	}
+}
+
+// promptTicker redraws the line every interval until done is closed so
+// that Config.PromptFunc is evaluated again.
+func (o *Operation) promptTicker(interval time.Duration, done chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			o.m.Lock()
+			if o.IsNormalMode() {
+				o.refresh()
+			}
+			o.m.Unlock()
 		}
-		return nil, err
 	}
 }
 
+// blinkMatchingParen highlights the opening bracket matching a closing
+// bracket before the cursor for blinkMatchingParenTime.
+func (o *Operation) blinkMatchingParen() {
+	id, ok := o.buf.BlinkMatch()
+	if !ok {
+		return
+	}
+	time.AfterFunc(blinkMatchingParenTime, func() {
+		o.m.Lock()
+		defer o.m.Unlock()
+		if !o.buf.EndBlink(id) {
+			return
+		}
+		if o.IsSearchMode() {
+			o.SearchRefresh(-1)
+		}
+		if o.IsInCompleteMode() {
+			o.CompleteRefresh()
+		}
+	})
+}
+
+func lineError(err error) ([]rune, error) {
+	if e, ok := err.(*InterruptError); ok {
+		return e.Line, ErrInterrupt
+	}
+	return nil, err
+}
+
 func (o *Operation) PasswordEx(prompt string, l Listener) ([]byte, error) {
 	cfg := o.GenPasswordConfig()
 	cfg.Prompt = prompt
@@ -506,6 +1013,9 @@ func (o *Operation) Slice() ([]byte, error) {
 }
 
 func (o *Operation) Close() {
+	o.m.Lock()
+	o.flushPrint()
+	o.m.Unlock()
 	select {
 	case o.errchan <- io.EOF:
 	default:
@@ -537,8 +1047,12 @@ func (op *Operation) SetConfig(cfg *Config) (*Config, error) {
 	old := op.cfg
 	op.cfg = cfg
 	op.SetPrompt(cfg.Prompt)
+	op.SetRightPrompt(cfg.RightPrompt)
 	op.SetMaskRune(cfg.MaskRune)
 	op.buf.SetConfig(cfg)
+	if cfg.AmbiguousWidth == 1 || cfg.AmbiguousWidth == 2 {
+		SetAmbiguousWidth(cfg.AmbiguousWidth)
+	}
 
 	if cfg.opHistory == nil {
 		op.SetHistoryPath(cfg.HistoryFile)
@@ -581,6 +1095,25 @@ func (o *Operation) refresh() {
 	}
 }
 
+// transientPrompt returns the prompt to redraw the finished line with, if
+// any, see Config.TransientPrompt.
+func (o *Operation) transientPrompt() (string, bool) {
+	cfg := o.GetConfig()
+	if cfg.FuncTransientPrompt != nil {
+		return cfg.FuncTransientPrompt(), true
+	}
+	return cfg.TransientPrompt, cfg.TransientPrompt != ""
+}
+
+// onSizeChange redraws the line after the terminal is resized so that the
+// right prompt is laid out for the new width.
+func (o *Operation) onSizeChange() {
+	if !o.buf.HasRightPrompt() {
+		return
+	}
+	o.Refresh()
+}
+
 func (o *Operation) Clean() {
 	o.buf.Clean()
 }
@@ -601,6 +1134,20 @@ type Listener interface {
 	OnChange(line []rune, pos int, key rune) (newLine []rune, newPos int, ok bool)
 }
 
+// Suggester suggests how the line being edited could continue, see
+// Config.EnableAutoSuggest.
+type Suggester interface {
+	// Suggest returns the text to show after line, or nil for none.
+	Suggest(line []rune) []rune
+}
+
+// SuggesterFunc adapts a function to the Suggester interface.
+type SuggesterFunc func(line []rune) []rune
+
+func (f SuggesterFunc) Suggest(line []rune) []rune {
+	return f(line)
+}
+
 type Painter interface {
 	Paint(line []rune, pos int) []rune
 }
@@ -0,0 +1,671 @@
+package readline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readTestLine feeds input to a non interactive instance and returns the
+// first line read
+func readTestLine(t *testing.T, cfg *Config, input string) string {
+	return readTestLineFrom(t, cfg, strings.NewReader(input))
+}
+
+func readTestLineFrom(t *testing.T, cfg *Config, input io.Reader) string {
+	rl := newTestInstance(t, cfg, input)
+	defer rl.Close()
+	line, err := rl.Readline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return line
+}
+
+func newTestInstance(t *testing.T, cfg *Config, input io.Reader) *Instance {
+	cfg.Stdin = ioutil.NopCloser(input)
+	if cfg.Stdout == nil {
+		cfg.Stdout = ioutil.Discard
+	}
+	cfg.Stderr = ioutil.Discard
+	cfg.FuncIsTerminal = func() bool { return false }
+	cfg.FuncMakeRaw = func() error { return nil }
+	cfg.FuncExitRaw = func() error { return nil }
+	if cfg.FuncGetSize == nil {
+		cfg.FuncGetSize = func() (int, int) { return 80, 24 }
+	}
+	cfg.FuncOnWidthChanged = func(func()) {}
+	rl, err := NewEx(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+// readTestScreen feeds input to an interactive instance drawing on a
+// testScreen of the given width and returns the screen and the cursor
+// position once the output has settled, before the line is finished.
+func readTestScreen(t *testing.T, cfg *Config, width int, input string) (string, int, int) {
+	return readTestScreenOn(t, cfg, newTestScreen(width), input)
+}
+
+func readTestScreenOn(t *testing.T, cfg *Config, s *testScreen, input string) (string, int, int) {
+	cfg.Stdout = s
+	cfg.ForceUseInteractive = true
+	cfg.FuncGetSize = func() (int, int) { return s.width, 24 }
+	r, w := io.Pipe()
+	rl := newTestInstance(t, cfg, r)
+	defer rl.Close()
+	defer w.Close()
+
+	go rl.Readline()
+	w.Write([]byte(input))
+	screen := waitScreen(s)
+	x, y := s.Cursor()
+	return screen, x, y
+}
+
+// waitScreen returns the screen once the output has settled.
+func waitScreen(s *testScreen) string {
+	prev := ""
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		cur := s.String()
+		if cur == prev {
+			break
+		}
+		prev = cur
+	}
+	return prev
+}
+
+func TestOperationKeymap(t *testing.T) {
+	km := DefaultEmacsKeymap()
+	km.Bind("\030\025", CmdUndo)
+	km.Bind("\027", CmdBackwardKillWord)
+	km.Unbind("\032")
+
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"abc\002\002X\r", "aXbc"},
+		{"one two\027\r", "one "},
+		{"one\030\025\r", ""},
+		{"a\032b\r", "ab"},
+	}
+	for _, test := range tests {
+		line := readTestLine(t, &Config{Keymap: km}, test.input)
+		testEqual(t, line, test.line, nil)
+	}
+}
+
+func TestOperationArgument(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"abcdef\0333\002X\r", "abcXdef"},
+		{"one two three\0332\033b\013\r", "one "},
+		{"abcdef\001\033-\0332\002X\r", "abXcdef"},
+		{"abc\0334x\r", "abcxxxx"},
+		{"abcd\033-2\010\r", "abcd"},
+		{"abcd\001\0332\004\r", "cd"},
+		{"abc\033999999999\002X\r", "Xabc"},
+		{"abc\001\033999999\024\r", "bac"},
+		{"abc\001\0331000000\024\r", "bca"},
+		{"ab\0331000000\037c\r", "c"},
+	}
+	for _, test := range tests {
+		line := readTestLine(t, &Config{}, test.input)
+		testEqual(t, line, test.line, nil)
+	}
+
+	// the argument is limited to maxArgument
+	line := readTestLine(t, &Config{}, "\0339999999999999999999x\r")
+	testEqual(t, line, strings.Repeat("x", maxArgument), nil)
+}
+
+func TestOperationBracketedPaste(t *testing.T) {
+	paste := "\033[200~one\r\ntwo\033[201~\r"
+	testEqual(t, readTestLine(t, &Config{}, paste), "one two", nil)
+	testEqual(t, readTestLine(t, &Config{BracketedPasteNewlines: true}, paste), "one\ntwo", nil)
+
+	cfg := &Config{
+		FuncFilterPaste: func(text []rune) []rune {
+			return []rune(strings.ToUpper(string(text)))
+		},
+	}
+	testEqual(t, readTestLine(t, cfg, "a"+paste), "aONE TWO", nil)
+
+	// escape sequences in the paste are not interpreted
+	testEqual(t, readTestLine(t, &Config{}, "\033[200~a\033[Db\033[201~\r"), "a\033[Db", nil)
+}
+
+func TestOperationModifierKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"one two\033[1;5DX\r", "one Xtwo"},
+		{"one two\033[1;3D\033[1;5D\033[1;5CX\r", "one Xtwo"},
+		{"one two\001\033[3;5~\r", " two"},
+		{"ab\033[DX\033[2~\033[15~\r", "aXb"},
+		// private use characters are not taken as special keys
+		{"a\uea60\ue900b\r", "a\uea60\ue900b"},
+	}
+	for _, test := range tests {
+		line := readTestLine(t, &Config{}, test.input)
+		testEqual(t, line, test.line, nil)
+	}
+}
+
+func TestOperationKittyKeyboard(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"one two\033[98;3uX\r", "one Xtwo"},
+		{"one two\033[117;5u\r", ""},
+		{"ab\033[121;3:1u\033[98;3:3uc\r", "abc"},
+		{"\033[?1u\033[?62;22cab\033[9;2u\r", "ab"},
+		{"abc\033[97;3u\033[117;5u\033[13u", ""},
+		{"ab\033[109;5u", "ab"},
+		{"one two\033[91;5ubX\r", "one Xtwo"},
+	}
+	for _, test := range tests {
+		line := readTestLine(t, &Config{}, test.input)
+		testEqual(t, line, test.line, nil)
+	}
+
+	// Ctrl-I and Ctrl-[ are bound apart from Tab and Esc
+	km := DefaultEmacsKeymap()
+	km.Bind(KeySeq(KeyCtrlI), CmdBackwardChar)
+	testEqual(t, readTestLine(t, &Config{Keymap: km}, "ab\033[105;5uX\r"), "aXb", nil)
+	testEqual(t, readTestLine(t, &Config{VimMode: true}, "ab\033[91;5uhiX\r"), "aXb", nil)
+}
+
+func TestOperationEscapeTimeout(t *testing.T) {
+	// escape sequences are parsed in vim mode
+	testEqual(t, readTestLine(t, &Config{VimMode: true}, "ab\033[DX\r"), "aXb", nil)
+
+	// a bare Esc is delivered after the timeout
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte("ab\033"))
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("hiX\r"))
+	}()
+	cfg := &Config{VimMode: true, EscapeTimeout: 10 * time.Millisecond}
+	testEqual(t, readTestLineFrom(t, cfg, r), "aXb", nil)
+}
+
+func TestOperationReadlineContext(t *testing.T) {
+	r, w := io.Pipe()
+	rl := newTestInstance(t, &Config{}, r)
+	defer rl.Close()
+	defer w.Close()
+
+	go w.Write([]byte("abc"))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	line, err := rl.ReadlineContext(ctx)
+	testEqual(t, line, "", nil)
+	testEqual(t, err, context.DeadlineExceeded, nil)
+
+	// the instance is reusable and starts with an empty line
+	go w.Write([]byte("def\r"))
+	line, err = rl.ReadlineContext(context.Background())
+	testEqual(t, line, "def", nil)
+	testEqual(t, err, nil, nil)
+}
+
+func TestOperationPrivateUseInput(t *testing.T) {
+	// private use characters are inserted, not taken as internal keys
+	done := make(chan string)
+	go func() {
+		done <- readTestLine(t, &Config{}, "a\ue009b\r")
+	}()
+	select {
+	case line := <-done:
+		testEqual(t, line, "a\ue009b", nil)
+	case <-time.After(2 * time.Second):
+		t.Fatal("line not returned")
+	}
+}
+
+func TestOperationAutoSuggest(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"git s\005\r", "git status"},
+		{"git c\033f\r", "git commit"},
+		{"git c\033[C\r", "git commit --amend"},
+		{"git c\r", "git c"},
+		{"git c\002\006\r", "git c"},
+	}
+	for _, test := range tests {
+		r, w := io.Pipe()
+		rl := newTestInstance(t, &Config{
+			EnableAutoSuggest:   true,
+			ForceUseInteractive: true,
+		}, r)
+		rl.SaveHistory("git status")
+		rl.SaveHistory("git commit --amend")
+		go func() {
+			w.Write([]byte(test.input))
+			w.Close()
+		}()
+		line, err := rl.Readline()
+		rl.Close()
+		testEqual(t, err, nil, nil)
+		testEqual(t, line, test.line, nil)
+	}
+
+	cfg := &Config{
+		EnableAutoSuggest:   true,
+		ForceUseInteractive: true,
+		Suggester: SuggesterFunc(func(line []rune) []rune {
+			return []rune("-suggested\nignored")
+		}),
+	}
+	testEqual(t, readTestLine(t, cfg, "a\005\r"), "a-suggested", nil)
+}
+
+func TestOperationMultiLinePrompt(t *testing.T) {
+	tests := []struct {
+		prompt string
+		width  int
+		input  string
+		screen string
+		x, y   int
+	}{
+		{"top\n> ", 10, "abcdefghijkl\001\005\002\002X", "top\n> abcdefgh\nijXkl", 3, 2},
+		{"0123456789abc\n> ", 10, "abcdefghijkl\001\002X", "0123456789\nabc\n> Xabcdefg\nhijkl", 3, 2},
+		{"\033[31mtop\033[0m\n\033[32m> \033[0m", 10, "abcdefghijkl\001", "top\n> abcdefgh\nijkl", 2, 1},
+		{"top\n> ", 40, "abc\022b", "top\n> abc\nbck-i-search: b", 3, 1},
+	}
+	for _, test := range tests {
+		screen, x, y := readTestScreen(t, &Config{Prompt: test.prompt}, test.width, test.input)
+		testEqual(t, screen, test.screen, nil)
+		testEqual(t, []int{x, y}, []int{test.x, test.y}, nil)
+	}
+}
+
+func TestOperationRightPrompt(t *testing.T) {
+	cfg := &Config{Prompt: "top\n> ", RightPrompt: "[r]"}
+	screen, _, _ := readTestScreen(t, cfg, 20, "abc")
+	testEqual(t, screen, "top\n> abc            [r]", nil)
+
+	cfg = &Config{Prompt: "top\n> ", RightPrompt: "[r]"}
+	screen, _, _ = readTestScreen(t, cfg, 20, "abcdefghijklmnopq")
+	testEqual(t, screen, "top\n> abcdefghijklmnopq", nil)
+}
+
+func TestOperationTransientPrompt(t *testing.T) {
+	cfg := &Config{
+		Prompt:          "long\n~/src> ",
+		RightPrompt:     "[main]",
+		TransientPrompt: "> ",
+	}
+	screen, x, y := readTestScreen(t, cfg, 20, "ls\r")
+	testEqual(t, screen, "> ls", nil)
+	testEqual(t, []int{x, y}, []int{0, 1}, nil)
+}
+
+func TestOperationPromptFunc(t *testing.T) {
+	var m sync.Mutex
+	n := 0
+	cfg := &Config{
+		PromptFunc: func() string {
+			m.Lock()
+			defer m.Unlock()
+			if n < 3 {
+				n++
+			}
+			return fmt.Sprintf("%d> ", n)
+		},
+		PromptRefreshInterval: 5 * time.Millisecond,
+	}
+	screen, x, y := readTestScreen(t, cfg, 20, "ab")
+	testEqual(t, screen, "3> ab", nil)
+	testEqual(t, []int{x, y}, []int{5, 0}, nil)
+}
+
+func TestOperationPromptEscapes(t *testing.T) {
+	prompt := "\033]8;;http://x\033\\dir\033]8;;\033\\\001\033]0;t\007\002> "
+	screen, x, y := readTestScreen(t, &Config{Prompt: prompt}, 20, "ab")
+	testEqual(t, screen, "dir> ab", nil)
+	testEqual(t, []int{x, y}, []int{7, 0}, nil)
+}
+
+func TestOperationAmbiguousWidthAuto(t *testing.T) {
+	defer SetAmbiguousWidth(1)
+	cfg := &Config{AmbiguousWidth: AmbiguousWidthAuto, ForceUseInteractive: true}
+	r, w := io.Pipe()
+	rl := newTestInstance(t, cfg, r)
+	defer rl.Close()
+	go func() {
+		// replies to the position queries before and after the probe
+		w.Write([]byte("\033[1;1R\033[1;3Rab\r"))
+		w.Close()
+	}()
+	line, err := rl.Readline()
+	testEqual(t, err, nil, nil)
+	testEqual(t, line, "ab", nil)
+	// the replies are handled asynchronously
+	for i := 0; i < 100 && runes.Width('±') != 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	testEqual(t, runes.Width('±'), 2, nil)
+}
+
+type testPainter struct{}
+
+func (testPainter) Paint(line []rune, _ int) []rune { return line }
+
+func TestOperationDiffRender(t *testing.T) {
+	long := strings.Repeat("abcdefghij", 5)
+	tests := []string{
+		long + "\001\006\006X",
+		long + "\002\002\002\010\010",
+		long + "\001\006\013",
+		long[:18] + "\002X\005\010\010",
+		long[:17] + "\001Y\005Z",
+		"ab\U0001F44Dcd\002\002\002\010e\u0301",
+		"ab\001\033[C\033[C\033[D\033[D",
+		"abcdefghijklmno\001\004\004",
+	}
+	for _, rprompt := range []string{"", "[r]"} {
+		for _, input := range tests {
+			cfg := &Config{Prompt: "> ", RightPrompt: rprompt}
+			screen, x, y := readTestScreen(t, cfg, 20, input)
+			// a Painter makes every change redraw the whole line
+			cfg = &Config{Prompt: "> ", RightPrompt: rprompt, Painter: testPainter{}}
+			fullScreen, fx, fy := readTestScreen(t, cfg, 20, input)
+			testEqual(t, screen, fullScreen, nil)
+			testEqual(t, []int{x, y}, []int{fx, fy}, nil)
+
+			cfg = &Config{Prompt: "> ", RightPrompt: rprompt, StatusFunc: statusPos}
+			screen, x, y = readTestScreen(t, cfg, 20, input)
+			cfg = &Config{Prompt: "> ", RightPrompt: rprompt, StatusFunc: statusPos, Painter: testPainter{}}
+			fullScreen, fx, fy = readTestScreen(t, cfg, 20, input)
+			testEqual(t, screen, fullScreen, nil)
+			testEqual(t, []int{x, y}, []int{fx, fy}, nil)
+		}
+	}
+
+	// moving the cursor does not redraw the line
+	input := long + strings.Repeat("\002", 20)
+	diff := newTestScreen(20)
+	readTestScreenOn(t, &Config{}, diff, input)
+	full := newTestScreen(20)
+	readTestScreenOn(t, &Config{Painter: testPainter{}}, full, input)
+	if diff.Written()*5 > full.Written() {
+		t.Fatalf("wrote %d bytes, expected less than a fifth of %d", diff.Written(), full.Written())
+	}
+}
+
+func balanced(line []rune) bool {
+	return strings.Count(string(line), "(") <= strings.Count(string(line), ")")
+}
+
+func TestOperationValidator(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"(a\rb)\r", "(a\nb)"},
+		{"(abc\rd\020X\016\005)\r", "(abcX\nd)"},
+		{"(abc\rd\020\002X\016\005)\r", "(abXc\nd)"},
+		{"(a\r\r\020\020\020)\r", "(a)\n\n"},
+	}
+	for _, test := range tests {
+		cfg := &Config{Validator: balanced, ContinuationPrompt: "... "}
+		testEqual(t, readTestLine(t, cfg, test.input), test.line, nil)
+	}
+
+	cfg := &Config{Prompt: "> ", Validator: balanced, ContinuationPrompt: "... "}
+	screen, x, y := readTestScreen(t, cfg, 20, "(abc\rd\020")
+	testEqual(t, screen, "> (abc\n... d", nil)
+	testEqual(t, []int{x, y}, []int{5, 0}, nil)
+
+	cfg = &Config{Prompt: "> ", Validator: balanced, ContinuationPrompt: "... "}
+	screen, x, y = readTestScreen(t, cfg, 20, "(a\rb)\r")
+	testEqual(t, screen, "> (a\n... b)", nil)
+	testEqual(t, []int{x, y}, []int{0, 2}, nil)
+}
+
+func TestOperationValidatorEOF(t *testing.T) {
+	// an incomplete line is returned at the end of the input
+	done := make(chan string)
+	go func() {
+		done <- readTestLine(t, &Config{Validator: balanced}, "(a\rb")
+	}()
+	select {
+	case line := <-done:
+		testEqual(t, line, "(a\nb", nil)
+	case <-time.After(2 * time.Second):
+		t.Fatal("line not returned at EOF")
+	}
+}
+
+func TestOperationEditRules(t *testing.T) {
+	cfg := &Config{
+		Validator: func(line []rune) bool {
+			return strings.HasSuffix(string(line), ";")
+		},
+		EditRules: &BracketEditRules{},
+	}
+	testEqual(t, readTestLine(t, cfg, "{\rx(\005;\r"), "{\n    x()\n};", nil)
+}
+
+func TestOperationMultiLineHistory(t *testing.T) {
+	f, err := ioutil.TempFile("", "readline-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	cfg := &Config{HistoryFile: f.Name(), Validator: balanced}
+	testEqual(t, readTestLine(t, cfg, "(a\rb)\r"), "(a\nb)", nil)
+	cfg = &Config{HistoryFile: f.Name(), Validator: balanced}
+	testEqual(t, readTestLine(t, cfg, "\020\020\020X\r"), "(aX\nb)", nil)
+}
+
+func TestOperationSearchHighlight(t *testing.T) {
+	f, err := ioutil.TempFile("", "readline-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("echo hello\n")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// the match is styled without moving the cursor
+	cfg := &Config{Prompt: "> ", HistoryFile: f.Name()}
+	screen, x, y := readTestScreen(t, cfg, 40, "\022ll")
+	testEqual(t, screen, "> echo hello\nbck-i-search: ll", nil)
+	testEqual(t, []int{x, y}, []int{9, 0}, nil)
+}
+
+func TestOperationBlinkMatchingParen(t *testing.T) {
+	defer func(d time.Duration) { blinkMatchingParenTime = d }(blinkMatchingParenTime)
+	blinkMatchingParenTime = time.Millisecond
+	cfg := &Config{EnableBlinkMatchingParen: true, EnableBracketHighlight: true}
+	testEqual(t, readTestLine(t, cfg, "f(\")\")x\r"), "f(\")\")x", nil)
+	screen, x, y := readTestScreen(t, &Config{Prompt: "> ", EnableBlinkMatchingParen: true}, 20, "(a)")
+	testEqual(t, screen, "> (a)", nil)
+	testEqual(t, []int{x, y}, []int{5, 0}, nil)
+}
+
+func statusPos(line []rune, pos int) string {
+	return fmt.Sprintf("-- %d/%d --", pos, len(line))
+}
+
+func TestOperationStatusLine(t *testing.T) {
+	cfg := &Config{Prompt: "> ", StatusFunc: statusPos}
+	screen, x, y := readTestScreen(t, cfg, 20, "abc\002")
+	testEqual(t, screen, "> abc\n-- 2/3 --", nil)
+	testEqual(t, []int{x, y}, []int{4, 0}, nil)
+
+	// long lines are cut and the status follows wrapped text
+	cfg = &Config{Prompt: "> ", StatusFunc: func(line []rune, pos int) string {
+		return "\033[7m" + strings.Repeat(string(line), 3) + "\033[0m"
+	}}
+	screen, x, y = readTestScreen(t, cfg, 10, "abcdefghij")
+	testEqual(t, screen, "> abcdefgh\nij\nabcdefghi", nil)
+	testEqual(t, []int{x, y}, []int{2, 1}, nil)
+
+	// removed when the line is finished
+	cfg = &Config{Prompt: "> ", StatusFunc: statusPos}
+	screen, x, y = readTestScreen(t, cfg, 20, "ab\r")
+	testEqual(t, screen, "> ab", nil)
+	testEqual(t, []int{x, y}, []int{0, 1}, nil)
+
+	// completion candidates are shown below the status
+	cfg = &Config{
+		Prompt:       "> ",
+		StatusFunc:   statusPos,
+		AutoComplete: NewPrefixCompleter(PcItem("alpha"), PcItem("alpine")),
+	}
+	screen, x, y = readTestScreen(t, cfg, 40, "al\t\t")
+	testEqual(t, screen, "> alp\n-- 3/3 --\nalpha    alpine", nil)
+	testEqual(t, []int{x, y}, []int{5, 0}, nil)
+}
+
+// promptCounter counts the prompts written to a testScreen.
+type promptCounter struct {
+	*testScreen
+	m sync.Mutex
+	n int
+}
+
+func (p *promptCounter) Write(b []byte) (int, error) {
+	p.m.Lock()
+	p.n += strings.Count(string(b), "> ")
+	p.m.Unlock()
+	return p.testScreen.Write(b)
+}
+
+func TestOperationPrintAbove(t *testing.T) {
+	s := &promptCounter{testScreen: newTestScreen(20)}
+	cfg := &Config{
+		Prompt:              "> ",
+		Stdout:              s,
+		ForceUseInteractive: true,
+		FuncGetSize:         func() (int, int) { return 20, 24 },
+	}
+	r, w := io.Pipe()
+	rl := newTestInstance(t, cfg, r)
+	defer rl.Close()
+	defer w.Close()
+
+	go rl.Readline()
+	w.Write([]byte("ab"))
+	waitScreen(s.testScreen)
+	rl.Write([]byte("partial"))
+	waitScreen(s.testScreen)
+
+	s.m.Lock()
+	s.n = 0
+	s.m.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rl.Printf("line %d", i)
+		}(i)
+	}
+	wg.Wait()
+	time.Sleep(2 * printAboveInterval)
+	screen := waitScreen(s.testScreen)
+
+	lines := strings.Split(screen, "\n")
+	testEqual(t, len(lines), 102, nil)
+	testEqual(t, lines[0], "partial", nil)
+	testEqual(t, lines[101], "> ab", nil)
+	seen := map[string]bool{}
+	for _, l := range lines[1:101] {
+		seen[l] = true
+	}
+	for i := 0; i < 100; i++ {
+		if !seen[fmt.Sprintf("line %d", i)] {
+			t.Errorf("line %d not printed", i)
+		}
+	}
+	x, y := s.Cursor()
+	testEqual(t, []int{x, y}, []int{4, 101}, nil)
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.n > 5 {
+		t.Errorf("redrew the prompt %d times for 100 lines", s.n)
+	}
+}
+
+func TestOperationPrintAboveNotPrompting(t *testing.T) {
+	var b bytes.Buffer
+	cfg := &Config{
+		Stdout:      &b,
+		FuncGetSize: func() (int, int) { return 20, 24 },
+	}
+	r, w := io.Pipe()
+	rl := newTestInstance(t, cfg, r)
+	defer rl.Close()
+	defer w.Close()
+
+	rl.PrintAbove("first")
+	b.WriteString("second\n")
+	testEqual(t, b.String(), "first\nsecond\n", nil)
+}
+
+func TestOperationLiveLine(t *testing.T) {
+	s := newTestScreen(20)
+	cfg := &Config{
+		Prompt:              "> ",
+		Stdout:              s,
+		ForceUseInteractive: true,
+		FuncGetSize:         func() (int, int) { return 20, 24 },
+	}
+	r, w := io.Pipe()
+	rl := newTestInstance(t, cfg, r)
+	defer rl.Close()
+	defer w.Close()
+	settle := func() string {
+		time.Sleep(2 * printAboveInterval)
+		return waitScreen(s)
+	}
+
+	go rl.Readline()
+	w.Write([]byte("ab"))
+	waitScreen(s)
+	rl.Write([]byte("partial"))
+	progress := rl.NewLiveLine("get 0%")
+	spinner := rl.NewLiveLine("|")
+	testEqual(t, settle(), "partial\nget 0%\n|\n> ab", nil)
+
+	rl.Write([]byte(" more\n"))
+	rl.PrintAbove("done")
+	progress.Set("get 50% " + strings.Repeat("#", 20))
+	testEqual(t, settle(), "partial more\ndone\nget 50% ###########\n|\n> ab", nil)
+	x, y := s.Cursor()
+	testEqual(t, []int{x, y}, []int{4, 4}, nil)
+
+	spinner.Remove()
+	w.Write([]byte("c"))
+	testEqual(t, settle(), "partial more\ndone\nget 50% ###########\n> abc", nil)
+
+	// the finished line replaces the live lines
+	w.Write([]byte("\r"))
+	testEqual(t, settle(), "partial more\ndone\n> abc", nil)
+}
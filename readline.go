@@ -18,7 +18,10 @@
 package readline
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"time"
 )
 
 type Instance struct {
@@ -29,7 +32,44 @@ type Instance struct {
 
 type Config struct {
 	// prompt supports ANSI escape sequence, so we can color some characters even in windows
+	// it can span multiple lines separated by \n, the input starts after the last line
+	// other escape sequences, such as OSC 8 hyperlinks, are not counted and
+	// text between \x01 and \x02 is treated as non-printing like in bash
 	Prompt string
+	// shown at the right edge of the first line, hidden if the line is
+	// too long for it. Supports ANSI escape sequences like Prompt.
+	RightPrompt string
+	// replaces the prompt of a finished line to keep the scrollback tidy,
+	// FuncTransientPrompt is used instead if set
+	TransientPrompt     string
+	FuncTransientPrompt func() string
+	// evaluated each time the line is redrawn and used instead of Prompt,
+	// also every PromptRefreshInterval if it is set, for example to show a
+	// clock
+	PromptFunc            func() string
+	PromptRefreshInterval time.Duration
+	// returns a status line drawn below the input, for example the vim
+	// mode, a hint or a validation error. It's called each time the line is
+	// redrawn, "" hides it and lines longer than the terminal are cut.
+	StatusFunc func(line []rune, pos int) string
+
+	// with Validator set Enter only finishes the line if it returns true,
+	// otherwise a newline is inserted and editing continues on the next
+	// line, which starts with ContinuationPrompt. Up and Down move between
+	// the lines before moving in history.
+	Validator          func(line []rune) (complete bool)
+	ContinuationPrompt string
+	// auto-indents new lines and closes brackets and quotes while typing,
+	// see BracketEditRules
+	EditRules EditRules
+	// highlight the bracket matching the one at the cursor, or the closing
+	// bracket before it, in MatchingBracketStyle which defaults to reverse
+	// video. Brackets in quotes or escaped with a backslash are skipped.
+	// EnableBlinkMatchingParen highlights the opening bracket for a moment
+	// when a closing bracket is typed. Neither works with a Painter.
+	EnableBracketHighlight   bool
+	MatchingBracketStyle     Style
+	EnableBlinkMatchingParen bool
 
 	// readline will persist historys to file where HistoryFile specified
 	HistoryFile string
@@ -39,6 +79,9 @@ type Config struct {
 	// enable case-insensitive history searching
 	HistorySearchFold bool
 
+	// specify the max number of kills remembered for yank-pop, it's 10 by default
+	KillRingLimit int
+
 	// AutoCompleter will called once user press TAB
 	AutoComplete AutoCompleter
 
@@ -47,6 +90,13 @@ type Config struct {
 	Listener Listener
 
 	Painter Painter
+	// StyledPainter styles parts of the line, it's used instead of
+	// Painter if set
+	StyledPainter StyledPainter
+
+	// Keymap binds key sequences to editing commands, DefaultEmacsKeymap
+	// is used if nil
+	Keymap *Keymap
 
 	// If VimMode is true, readline will in vim.insert mode by default
 	VimMode bool
@@ -74,6 +124,40 @@ type Config struct {
 	// -> output = new (translated) rune and true/false if continue with processing this one
 	FuncFilterInputRune func(rune) (rune, bool)
 
+	// enable bracketed paste so pasted text is inserted as is instead of
+	// being handled as key presses, newlines are replaced by spaces unless
+	// BracketedPasteNewlines is set
+	EnableBracketedPaste   bool
+	BracketedPasteNewlines bool
+	// filter pasted text, the returned text is inserted into the buffer
+	FuncFilterPaste func([]rune) []rune
+
+	// how long to wait for the rest of an escape sequence after Esc before
+	// treating it as a bare Esc key, defaults to 100ms. A negative value
+	// waits indefinitely in emacs mode and never parses sequences in vim
+	// mode.
+	EscapeTimeout time.Duration
+
+	// show a suggested rest of the line dimmed after the cursor while
+	// typing, moving forward or to the end of the line accepts it and
+	// forward word accepts one word of it. Suggestions are the most
+	// recent history item starting with the line unless Suggester is set.
+	EnableAutoSuggest bool
+	Suggester         Suggester
+
+	// negotiate the kitty keyboard protocol with terminals supporting it,
+	// this makes Esc and Alt+key unambiguous and reports Ctrl-I, Ctrl-M and
+	// Ctrl-[ as KeyCtrlI, KeyCtrlM and KeyCtrlBracket. Terminals not
+	// answering the query keep using legacy key sequences.
+	EnableKittyKeyboard bool
+
+	// width of East Asian ambiguous characters such as ± and ①, 1 or 2.
+	// AmbiguousWidthAuto measures it by printing a probe character once.
+	// The width functions are package-level, so the field sets the width
+	// with SetAmbiguousWidth for all instances. 0 keeps the current width
+	// which defaults to 1.
+	AmbiguousWidth int
+
 	// force use interactive even stdout is not a tty
 	FuncIsTerminal      func() bool
 	FuncMakeRaw         func() error
@@ -114,6 +198,12 @@ func (c *Config) Init() error {
 	if c.HistoryLimit == 0 {
 		c.HistoryLimit = 500
 	}
+	if c.KillRingLimit <= 0 {
+		c.KillRingLimit = 10
+	}
+	if c.EscapeTimeout == 0 {
+		c.EscapeTimeout = 100 * time.Millisecond
+	}
 
 	if c.InterruptPrompt == "" {
 		c.InterruptPrompt = "^C"
@@ -129,6 +219,9 @@ func (c *Config) Init() error {
 	if c.AutoComplete == nil {
 		c.AutoComplete = &TabCompleter{}
 	}
+	if c.Keymap == nil {
+		c.Keymap = DefaultEmacsKeymap()
+	}
 	if c.FuncGetWidth == nil {
 		c.FuncGetWidth = GetScreenWidth
 	}
@@ -194,6 +287,10 @@ func (i *Instance) SetPrompt(s string) {
 	i.Operation.SetPrompt(s)
 }
 
+func (i *Instance) SetRightPrompt(s string) {
+	i.Operation.SetRightPrompt(s)
+}
+
 func (i *Instance) SetMaskRune(r rune) {
 	i.Operation.SetMaskRune(r)
 }
@@ -222,6 +319,12 @@ func (i *Instance) IsVimMode() bool {
 	return i.Operation.IsEnableVimMode()
 }
 
+// IsVimNormalMode returns true if vim mode is enabled and in normal mode,
+// for example to show the mode with Config.StatusFunc.
+func (i *Instance) IsVimNormalMode() bool {
+	return i.Operation.IsVimNormalMode()
+}
+
 func (i *Instance) GenPasswordConfig() *Config {
 	return i.Operation.GenPasswordConfig()
 }
@@ -262,6 +365,13 @@ func (i *Instance) Readline() (string, error) {
 	return i.Operation.String()
 }
 
+// ReadlineContext is like Readline but aborts when ctx is canceled or its
+// deadline passes. The line is erased, ctx.Err() is returned and the
+// instance can be used to read the next line.
+func (i *Instance) ReadlineContext(ctx context.Context) (string, error) {
+	return i.Operation.StringContext(ctx)
+}
+
 func (i *Instance) ReadlineWithDefault(what string) (string, error) {
 	i.Operation.SetBuffer(what)
 	return i.Operation.String()
@@ -303,6 +413,23 @@ func (i *Instance) Write(b []byte) (int, error) {
 	return i.Stdout().Write(b)
 }
 
+// PrintAbove prints s as complete lines above the prompt, unlike Write it
+// can be called often as lines printed together are drawn with one redraw.
+func (i *Instance) PrintAbove(s string) {
+	i.Operation.PrintAbove(s)
+}
+
+// Printf is like PrintAbove with the arguments formatted as by fmt.Sprintf.
+func (i *Instance) Printf(format string, a ...interface{}) {
+	i.Operation.PrintAbove(fmt.Sprintf(format, a...))
+}
+
+// NewLiveLine adds a line drawn between the output and the prompt, which
+// can be updated while reading a line, see LiveLine.
+func (i *Instance) NewLiveLine(text string) *LiveLine {
+	return i.Operation.NewLiveLine(text)
+}
+
 // WriteStdin prefill the next Stdin fetch
 // Next time you call ReadLine() this value will be writen before the user input
 // ie :
@@ -7,6 +7,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"unicode"
 )
 
 type runeBufferBck struct {
@@ -14,11 +15,23 @@ type runeBufferBck struct {
 	idx int
 }
 
+type runeBufferPrompts struct {
+	prompt  []rune
+	rprompt []rune
+}
+
 type RuneBuffer struct {
-	buf    []rune
-	idx    int
-	prompt []rune
-	w      *Terminal
+	buf     []rune
+	idx     int
+	prompt  []rune
+	rprompt []rune // right prompt, see Config.RightPrompt
+	// prompts replaced by Transient, restored by Reset
+	transient *runeBufferPrompts
+	drawn     *renderState // nil if unknown
+	done      bool         // line finished, see Finish
+	mark      Span         // styled runes, see SetStyle
+	status    []string     // lines drawn below the text, see Config.StatusFunc
+	w         *Terminal
 
 	interactive bool
 	cfg         *Config
@@ -28,16 +41,80 @@ type RuneBuffer struct {
 	offset string // is offset useful? scrolling means row varies
 	ppos   int    // prompt start position (0 == column 1)
 
-	lastKill []rune
+	killRing [][]rune // most recent kill last
+	killIdx  int      // index in killRing of the last yanked text
+	yankLen  int      // length of the last yanked text
+
+	// kind of edit done by the current and the previous command, used
+	// to append consecutive kills and to only yank-pop after a yank
+	lastOp int
+	op     int
 
 	lastChangeIdx int
 	OnChange      func(pos int, buf []rune)
 
+	// suggested rest of the line shown after the cursor, see
+	// Config.EnableAutoSuggest. HistorySuggest is used when there is
+	// no Config.Suggester.
+	suggestion     []rune
+	HistorySuggest func(line []rune) []rune
+
+	// opening bracket highlighted by BlinkMatch until EndBlink
+	blinking bool
+	blinkIdx int
+	blinkID  int
+
+	// lines drawn above the prompt, see Operation.NewLiveLine, taking up
+	// liveRows rows from column liveCol
+	live     []string
+	liveRows int
+	liveCol  int
+
 	sync.Mutex
 }
 
-func (r *RuneBuffer) pushKill(text []rune) {
-	r.lastKill = append([]rune{}, text...)
+const (
+	opOther = iota
+	opKill
+	opYank
+)
+
+const (
+	killForward  = iota // text after the cursor, appended to previous kill
+	killBackward        // text before the cursor, prepended to previous kill
+	killSingle          // never joined with previous kill
+)
+
+// StartCommand is called before each editing command to track if kills
+// are consecutive.
+func (r *RuneBuffer) StartCommand() {
+	r.Lock()
+	r.lastOp = r.op
+	r.op = opOther
+	r.Unlock()
+}
+
+// pushKill adds text to the kill ring, or joins it with the previous kill
+// if the previous or current command already killed text.
+func (r *RuneBuffer) pushKill(text []rune, dir int) {
+	text = append([]rune{}, text...)
+	if dir != killSingle && (r.lastOp == opKill || r.op == opKill) && len(r.killRing) > 0 {
+		last := r.killRing[len(r.killRing)-1]
+		if dir == killBackward {
+			text = append(text, last...)
+		} else {
+			text = append(last, text...)
+		}
+		r.killRing[len(r.killRing)-1] = text
+	} else if len(text) > 0 {
+		r.killRing = append(r.killRing, text)
+		if limit := r.cfg.KillRingLimit; limit > 0 && len(r.killRing) > limit {
+			r.killRing = r.killRing[len(r.killRing)-limit:]
+		}
+	}
+	if dir != killSingle {
+		r.op = opKill
+	}
 }
 
 func (r *RuneBuffer) Backup() {
@@ -47,7 +124,7 @@ func (r *RuneBuffer) Backup() {
 }
 
 func (r *RuneBuffer) Restore() {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.bck == nil {
 			return
 		}
@@ -91,8 +168,23 @@ func (r *RuneBuffer) PromptLen() int {
 	return r.promptLen()
 }
 
+// promptLen returns the width of the last line of the prompt, the line
+// the input starts on.
 func (r *RuneBuffer) promptLen() int {
-	return runes.WidthAll(runes.ColorFilter(r.prompt))
+	p := runes.ColorFilter(r.prompt)
+	if i := runes.IndexAllBck(p, []rune{'\n'}); i >= 0 {
+		p = p[i+1:]
+	}
+	return runes.WidthAll(p)
+}
+
+// promptLineIdx returns the index of the screen line the input starts on,
+// relative to the first line of the prompt.
+func (r *RuneBuffer) promptLineIdx(tWidth int) int {
+	if tWidth == 0 {
+		return 0
+	}
+	return len(SplitByLine(runes.ColorFilter(r.prompt), nil, r.ppos, tWidth, 0)) - 1
 }
 
 func (r *RuneBuffer) RuneSlice(i int) []rune {
@@ -123,6 +215,13 @@ func (r *RuneBuffer) Pos() int {
 	return r.idx
 }
 
+// ShownLen returns the length of the line and the suggestion shown after it.
+func (r *RuneBuffer) ShownLen() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.shownRunes())
+}
+
 func (r *RuneBuffer) Len() int {
 	r.Lock()
 	defer r.Unlock()
@@ -130,7 +229,7 @@ func (r *RuneBuffer) Len() int {
 }
 
 func (r *RuneBuffer) MoveToLineStart() {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == 0 {
 			return
 		}
@@ -139,11 +238,11 @@ func (r *RuneBuffer) MoveToLineStart() {
 }
 
 func (r *RuneBuffer) MoveBackward() {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == 0 {
 			return
 		}
-		r.idx--
+		r.idx = graphemePrev(r.buf, r.idx)
 	})
 }
 
@@ -159,30 +258,106 @@ func (r *RuneBuffer) WriteRunes(s []rune) {
 	r.Lock()
 	defer r.Unlock()
 
-	if r.idx == len(r.buf) {
+	if r.canAppend() {
 		// cursor is already at end of buf data so just call
 		// append instead of refesh to save redrawing.
 		r.buf = append(r.buf, s...)
 		r.idx += len(s)
 		if r.interactive {
+			valid := r.drawnValid()
 			r.append(s)
+			if valid {
+				r.drawn = r.renderState()
+			} else {
+				r.drawn = nil
+			}
 		}
 	} else {
 		// writing into the data somewhere so do a refresh
-		r.refresh(func() {
-			tail := append(s, r.buf[r.idx:]...)
-			r.buf = append(r.buf[:r.idx], tail...)
-			r.idx += len(s)
+		r.redraw(func() {
+			r.insert(s)
 		})
 	}
 }
 
+// insert inserts s at the cursor and moves the cursor after it.
+func (r *RuneBuffer) insert(s []rune) {
+	tail := append(runes.Copy(s), r.buf[r.idx:]...)
+	r.buf = append(r.buf[:r.idx], tail...)
+	r.idx += len(s)
+}
+
+// Type inserts the typed rune ch applying Config.EditRules, a closing rune
+// of a pair types over the same rune after the cursor and an opening rune
+// is followed by its closing rune unless it is typed before a word.
+func (r *RuneBuffer) Type(ch rune) {
+	rules := r.cfg.EditRules
+	if rules == nil {
+		r.WriteRune(ch)
+		return
+	}
+	r.update(func() {
+		var prev, next rune
+		if r.idx > 0 {
+			prev = r.buf[r.idx-1]
+		}
+		if r.idx < len(r.buf) {
+			next = r.buf[r.idx]
+		}
+		if next == ch && isPairCloser(rules, ch) {
+			r.idx++
+			return
+		}
+		r.insert([]rune{ch})
+		c := pairCloser(rules, ch)
+		if c == 0 || isWordRune(next) || (c == ch && isWordRune(prev)) {
+			return
+		}
+		r.buf = append(r.buf[:r.idx], append([]rune{c}, r.buf[r.idx:]...)...)
+	})
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// NewLine inserts a newline followed by the indentation from
+// Config.EditRules. Between an opening and closing rune of a pair the
+// closing rune is moved to a line of its own after the cursor.
+func (r *RuneBuffer) NewLine() {
+	r.update(func() {
+		rules := r.cfg.EditRules
+		if rules == nil {
+			r.insert([]rune{'\n'})
+			return
+		}
+		line := r.buf[r.lineStart(r.idx):r.idx]
+		between := len(line) > 0 && r.idx < len(r.buf) &&
+			pairCloser(rules, line[len(line)-1]) == r.buf[r.idx]
+		base := leadingSpace(line)
+		r.insert(append([]rune{'\n'}, rules.Indent(line)...))
+		if between {
+			tail := append([]rune{'\n'}, base...)
+			r.buf = append(r.buf[:r.idx], append(tail, r.buf[r.idx:]...)...)
+		}
+	})
+}
+
+// canAppend returns true if runes written at the end of the buffer can be
+// drawn by appending them instead of redrawing the line.
+func (r *RuneBuffer) canAppend() bool {
+	return r.idx == len(r.buf) && !r.suggesting() &&
+		r.cfg.StyledPainter == nil && len(r.rprompt) == 0 &&
+		r.cfg.ContinuationPrompt == "" && !r.cfg.EnableBracketHighlight &&
+		!r.blinking && r.mark.End <= r.mark.Start && r.cfg.StatusFunc == nil
+}
+
 func (r *RuneBuffer) MoveForward() {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == len(r.buf) {
 			return
 		}
-		r.idx++
+		r.idx = graphemeNext(r.buf, r.idx)
 	})
 }
 
@@ -193,26 +368,27 @@ func (r *RuneBuffer) IsCursorInEnd() bool {
 }
 
 func (r *RuneBuffer) Replace(ch rune) {
-	r.Refresh(func() {
+	r.update(func() {
 		r.buf[r.idx] = ch
 	})
 }
 
 func (r *RuneBuffer) Erase() {
-	r.Refresh(func() {
+	r.update(func() {
 		r.idx = 0
-		r.pushKill(r.buf[:])
+		r.pushKill(r.buf[:], killSingle)
 		r.buf = r.buf[:0]
 	})
 }
 
 func (r *RuneBuffer) Delete() (success bool) {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == len(r.buf) {
 			return
 		}
-		r.pushKill(r.buf[r.idx : r.idx+1])
-		r.buf = append(r.buf[:r.idx], r.buf[r.idx+1:]...)
+		// like delete-char, deleted characters don't go to the kill ring
+		end := graphemeNext(r.buf, r.idx)
+		r.buf = append(r.buf[:r.idx], r.buf[end:]...)
 		success = true
 	})
 	return
@@ -228,8 +404,8 @@ func (r *RuneBuffer) DeleteWord() {
 	}
 	for i := init + 1; i < len(r.buf); i++ {
 		if !IsWordBreak(r.buf[i]) && IsWordBreak(r.buf[i-1]) {
-			r.pushKill(r.buf[r.idx : i-1])
-			r.Refresh(func() {
+			r.pushKill(r.buf[r.idx:i-1], killForward)
+			r.update(func() {
 				r.buf = append(r.buf[:r.idx], r.buf[i-1:]...)
 			})
 			return
@@ -239,7 +415,7 @@ func (r *RuneBuffer) DeleteWord() {
 }
 
 func (r *RuneBuffer) MoveToPrevWord() (success bool) {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == 0 {
 			return
 		}
@@ -258,13 +434,13 @@ func (r *RuneBuffer) MoveToPrevWord() (success bool) {
 }
 
 func (r *RuneBuffer) KillFront() {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == 0 {
 			return
 		}
 
 		length := len(r.buf) - r.idx
-		r.pushKill(r.buf[:r.idx])
+		r.pushKill(r.buf[:r.idx], killBackward)
 		copy(r.buf[:length], r.buf[r.idx:])
 		r.idx = 0
 		r.buf = r.buf[:length]
@@ -272,34 +448,35 @@ func (r *RuneBuffer) KillFront() {
 }
 
 func (r *RuneBuffer) Kill() {
-	r.Refresh(func() {
-		r.pushKill(r.buf[r.idx:])
+	r.update(func() {
+		r.pushKill(r.buf[r.idx:], killForward)
 		r.buf = r.buf[:r.idx]
 	})
 }
 
 func (r *RuneBuffer) Transpose() {
-	r.Refresh(func() {
-		if len(r.buf) == 1 {
-			r.idx++
-		}
-
-		if len(r.buf) < 2 {
+	r.update(func() {
+		if graphemeNext(r.buf, 0) == len(r.buf) {
+			r.idx = len(r.buf)
 			return
 		}
 
-		if r.idx == 0 {
-			r.idx = 1
-		} else if r.idx >= len(r.buf) {
-			r.idx = len(r.buf) - 1
+		// swap the characters before and at mid
+		mid := r.idx
+		if mid == 0 {
+			mid = graphemeNext(r.buf, 0)
+		} else if mid >= len(r.buf) {
+			mid = graphemePrev(r.buf, len(r.buf))
 		}
-		r.buf[r.idx], r.buf[r.idx-1] = r.buf[r.idx-1], r.buf[r.idx]
-		r.idx++
+		start, end := graphemePrev(r.buf, mid), graphemeNext(r.buf, mid)
+		swapped := append(runes.Copy(r.buf[mid:end]), r.buf[start:mid]...)
+		copy(r.buf[start:end], swapped)
+		r.idx = end
 	})
 }
 
 func (r *RuneBuffer) MoveToNextWord() {
-	r.Refresh(func() {
+	r.update(func() {
 		for i := r.idx + 1; i < len(r.buf); i++ {
 			if !IsWordBreak(r.buf[i]) && IsWordBreak(r.buf[i-1]) {
 				r.idx = i
@@ -312,7 +489,7 @@ func (r *RuneBuffer) MoveToNextWord() {
 }
 
 func (r *RuneBuffer) MoveToEndWord() {
-	r.Refresh(func() {
+	r.update(func() {
 		// already at the end, so do nothing
 		if r.idx == len(r.buf) {
 			return
@@ -334,13 +511,13 @@ func (r *RuneBuffer) MoveToEndWord() {
 }
 
 func (r *RuneBuffer) BackEscapeWord() {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == 0 {
 			return
 		}
 		for i := r.idx - 1; i >= 0; i-- {
 			if i == 0 || (IsWordBreak(r.buf[i-1])) && !IsWordBreak(r.buf[i]) {
-				r.pushKill(r.buf[i:r.idx])
+				r.pushKill(r.buf[i:r.idx], killBackward)
 				r.buf = append(r.buf[:i], r.buf[r.idx:]...)
 				r.idx = i
 				return
@@ -353,27 +530,61 @@ func (r *RuneBuffer) BackEscapeWord() {
 }
 
 func (r *RuneBuffer) Yank() {
-	if len(r.lastKill) == 0 {
-		return
-	}
-	r.Refresh(func() {
-		buf := make([]rune, 0, len(r.buf)+len(r.lastKill))
-		buf = append(buf, r.buf[:r.idx]...)
-		buf = append(buf, r.lastKill...)
-		buf = append(buf, r.buf[r.idx:]...)
-		r.buf = buf
-		r.idx += len(r.lastKill)
+	r.update(func() {
+		if len(r.killRing) == 0 {
+			return
+		}
+		r.killIdx = len(r.killRing) - 1
+		r.insertYank(r.idx, r.killRing[r.killIdx])
 	})
 }
 
+// YankPop replaces the text inserted by the previous Yank or YankPop with
+// the kill before it in the kill ring. Returns false if the previous
+// command was not a yank.
+func (r *RuneBuffer) YankPop() (success bool) {
+	r.update(func() {
+		if r.lastOp != opYank || len(r.killRing) == 0 {
+			return
+		}
+		r.killIdx--
+		if r.killIdx < 0 {
+			r.killIdx = len(r.killRing) - 1
+		}
+		start := r.idx - r.yankLen
+		r.buf = append(r.buf[:start], r.buf[r.idx:]...)
+		r.insertYank(start, r.killRing[r.killIdx])
+		success = true
+	})
+	return
+}
+
+func (r *RuneBuffer) insertYank(idx int, text []rune) {
+	buf := make([]rune, 0, len(r.buf)+len(text))
+	buf = append(buf, r.buf[:idx]...)
+	buf = append(buf, text...)
+	buf = append(buf, r.buf[idx:]...)
+	r.buf = buf
+	r.idx = idx + len(text)
+	r.yankLen = len(text)
+	r.op = opYank
+}
+
 func (r *RuneBuffer) Backspace() {
-	r.Refresh(func() {
+	r.update(func() {
 		if r.idx == 0 {
 			return
 		}
 
-		r.idx--
-		r.buf = append(r.buf[:r.idx], r.buf[r.idx+1:]...)
+		start := graphemePrev(r.buf, r.idx)
+		end := r.idx
+		// deleting the opening rune of an empty pair deletes both
+		if rules := r.cfg.EditRules; rules != nil && end < len(r.buf) &&
+			end-start == 1 && pairCloser(rules, r.buf[start]) == r.buf[end] {
+			end++
+		}
+		r.buf = append(r.buf[:start], r.buf[end:]...)
+		r.idx = start
 	})
 }
 
@@ -383,19 +594,142 @@ func (r *RuneBuffer) MoveToLineEnd() {
 	if r.idx == len(r.buf) {
 		return
 	}
-	r.refresh(func() {
+	r.redraw(func() {
 		r.idx = len(r.buf)
 	})
 }
 
+// Finish ends the line with s and a newline moving the cursor below it,
+// unlike WriteString no continuation prompt is drawn after the newline and
+// the status line is removed.
+func (r *RuneBuffer) Finish(s string) {
+	r.Lock()
+	r.done = true
+	if r.liveRows > 0 {
+		r.refresh(nil) // the live lines are not kept above the finished line
+	}
+	r.Unlock()
+	r.WriteString(s + "\n")
+}
+
+// continues returns true if the newline at i in the buffer is followed by
+// a continuation prompt.
+func (r *RuneBuffer) continues(i int) bool {
+	return r.cfg.ContinuationPrompt != "" && !(r.done && i == len(r.buf)-1)
+}
+
+// displayRunes returns rs, the start of the buffer or shown runes, as it is
+// laid out on the screen with the continuation prompts.
+func (r *RuneBuffer) displayRunes(rs []rune) []rune {
+	if r.cfg.ContinuationPrompt == "" || runes.Index('\n', rs) == -1 {
+		return rs
+	}
+	prompt := runes.ColorFilter([]rune(r.cfg.ContinuationPrompt))
+	out := make([]rune, 0, len(rs))
+	for i, e := range rs {
+		out = append(out, e)
+		if e == '\n' && r.continues(i) {
+			out = append(out, prompt...)
+		}
+	}
+	return out
+}
+
+// writeText writes rs, starting at offset in the buffer, in styles with a
+// continuation prompt after each newline.
+func (r *RuneBuffer) writeText(buf *bytes.Buffer, rs []rune, styles []Style, offset int) {
+	prompt := string(runes.StripPromptMarkers([]rune(r.cfg.ContinuationPrompt)))
+	start := 0
+	for i, e := range rs {
+		if e != '\n' || !r.continues(offset+i) {
+			continue
+		}
+		writeStyled(buf, rs[start:i+1], subStyles(styles, start, i+1))
+		buf.WriteString(prompt)
+		start = i + 1
+	}
+	writeStyled(buf, rs[start:], subStyles(styles, start, len(rs)))
+}
+
+func subStyles(styles []Style, start, end int) []Style {
+	if styles == nil {
+		return nil
+	}
+	return styles[start:end]
+}
+
+// lineStart returns the index in the buffer of the start of the line i is
+// on, lines are separated by newlines.
+func (r *RuneBuffer) lineStart(i int) int {
+	for i > 0 && r.buf[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+// lineColumn returns the screen column of i in the buffer.
+func (r *RuneBuffer) lineColumn(i int) int {
+	start := r.lineStart(i)
+	prompt := r.promptLen()
+	if start > 0 {
+		prompt = runes.WidthAll(runes.ColorFilter([]rune(r.cfg.ContinuationPrompt)))
+	}
+	return prompt + runes.WidthAll(r.buf[start:i])
+}
+
+// moveToColumn returns the index on the line starting at start closest to
+// but not past the screen column.
+func (r *RuneBuffer) moveToColumn(start, column int) int {
+	i := start
+	for i < len(r.buf) && r.buf[i] != '\n' {
+		next := graphemeNext(r.buf, i)
+		if r.lineColumn(next) > column {
+			break
+		}
+		i = next
+	}
+	return i
+}
+
+// MoveLineUp moves the cursor to the previous line of a multi-line buffer,
+// it returns false if the cursor is on the first line.
+func (r *RuneBuffer) MoveLineUp() (success bool) {
+	r.update(func() {
+		start := r.lineStart(r.idx)
+		if start == 0 {
+			return
+		}
+		r.idx = r.moveToColumn(r.lineStart(start-1), r.lineColumn(r.idx))
+		success = true
+	})
+	return
+}
+
+// MoveLineDown moves the cursor to the next line of a multi-line buffer,
+// it returns false if the cursor is on the last line.
+func (r *RuneBuffer) MoveLineDown() (success bool) {
+	r.update(func() {
+		end := r.idx
+		for end < len(r.buf) && r.buf[end] != '\n' {
+			end++
+		}
+		if end == len(r.buf) {
+			return
+		}
+		r.idx = r.moveToColumn(end+1, r.lineColumn(r.idx))
+		success = true
+	})
+	return
+}
+
 // LineCount returns number of lines the buffer takes as it appears in the terminal.
 func (r *RuneBuffer) LineCount() int {
-	sp := r.getSplitByLine(r.buf, 1)
+	sp := r.getSplitByLine(r.shownRunes(), 1)
 	return len(sp)
 }
 
 func (r *RuneBuffer) MoveTo(ch rune, prevChar, reverse bool) (success bool) {
-	r.Refresh(func() {
+	r.update(func() {
 		if reverse {
 			for i := r.idx - 1; i >= 0; i-- {
 				if r.buf[i] == ch {
@@ -427,7 +761,7 @@ func (r *RuneBuffer) isInLineEdge() bool {
 	if isWindows {
 		return false
 	}
-	sp := r.getSplitByLine(r.buf, 1)
+	sp := r.getSplitByLine(r.shownRunes(), 1)
 	return len(sp[len(sp)-1]) == 0 // last line is 0 len
 }
 
@@ -438,7 +772,7 @@ func (r *RuneBuffer) getSplitByLine(rs []rune, nextWidth int) [][]rune {
 		masked := []rune(strings.Repeat(string(r.cfg.MaskRune), len(rs)))
 		return SplitByLine(runes.ColorFilter(r.prompt), masked, r.ppos, tWidth, w)
 	} else {
-		return SplitByLine(runes.ColorFilter(r.prompt), rs, r.ppos, tWidth, nextWidth)
+		return SplitByLine(runes.ColorFilter(r.prompt), r.displayRunes(rs), r.ppos, tWidth, nextWidth)
 	}
 }
 
@@ -454,15 +788,22 @@ func (r *RuneBuffer) idxLine(width int) int {
 	}
 	nextWidth := 1
 	if r.idx < len(r.buf) {
-		nextWidth = runes.Width(r.buf[r.idx])
+		nextWidth = graphemeWidth(r.buf[r.idx:graphemeNext(r.buf, r.idx)])
 	}
 	sp := r.getSplitByLine(r.buf[:r.idx], nextWidth)
 	return len(sp) - 1
 }
 
+// CursorLineCount returns the number of lines from the cursor to below the
+// text and the status line.
 func (r *RuneBuffer) CursorLineCount() int {
 	tWidth, _ := r.w.GetWidthHeight()
-	return r.LineCount() - r.IdxLine(tWidth)
+	return r.LineCount() - r.IdxLine(tWidth) + len(r.status)
+}
+
+// StatusLineCount returns the number of status lines drawn below the text.
+func (r *RuneBuffer) StatusLineCount() int {
+	return len(r.status)
 }
 
 func (r *RuneBuffer) Refresh(f func()) {
@@ -471,7 +812,27 @@ func (r *RuneBuffer) Refresh(f func()) {
 	r.refresh(f)
 }
 
+// refresh applies f and draws the prompt and buffer again after erasing
+// them, f may write to the terminal in between.
 func (r *RuneBuffer) refresh(f func()) {
+	r.render(f, false)
+}
+
+// update is like Refresh but only redraws what changed, see redraw.
+func (r *RuneBuffer) update(f func()) {
+	r.Lock()
+	defer r.Unlock()
+	r.redraw(f)
+}
+
+// redraw is like refresh but when nothing else has written to the terminal
+// since the line was drawn it only redraws the part of the line after the
+// first change, f must not write to the terminal.
+func (r *RuneBuffer) redraw(f func()) {
+	r.render(f, true)
+}
+
+func (r *RuneBuffer) render(f func(), diff bool) {
 	prevIdx := r.idx
 	prevBuf := append([]rune{}, r.buf...)
 
@@ -482,11 +843,25 @@ func (r *RuneBuffer) refresh(f func()) {
 		return
 	}
 
-	r.clean()
+	diff = diff && r.drawnValid()
+	idxLine := 0
+	if diff {
+		tWidth, _ := r.w.GetWidthHeight()
+		idxLine = r.idxLine(tWidth)
+	} else {
+		r.clean()
+	}
 	if f != nil {
 		f()
 	}
-	r.print()
+	if !runes.Equal(r.buf, prevBuf) {
+		r.updateSuggestion()
+	}
+	if diff {
+		r.printDiff(idxLine)
+	} else {
+		r.print()
+	}
 
 	if r.OnChange != nil {
 		if !runes.Equal(r.buf, prevBuf) {
@@ -500,6 +875,28 @@ func (r *RuneBuffer) refresh(f func()) {
 
 }
 
+// moveCursor writes the sequence moving the cursor from line idxLine to
+// where the rune at i in text is drawn.
+func (r *RuneBuffer) moveCursor(buf *bytes.Buffer, text []rune, idxLine, i int) {
+	tWidth, _ := r.w.GetWidthHeight()
+	nextWidth := 1
+	if i < len(text) {
+		nextWidth = graphemeWidth(text[i:graphemeNext(text, i)])
+	}
+	sp := SplitByLine(runes.ColorFilter(r.prompt), r.displayRunes(text[:i]), r.ppos, tWidth, nextWidth)
+	row := len(sp) - 1
+	column := runes.WidthAll(sp[row])
+	if row == 0 {
+		column += r.ppos
+	}
+	if up := idxLine - row; up > 0 {
+		fmt.Fprintf(buf, "\033[%dA", up)
+	} else if up < 0 {
+		fmt.Fprintf(buf, "\033[%dB", -up)
+	}
+	fmt.Fprintf(buf, "\033[%dG", column+1)
+}
+
 // getAndSetOffset queries the terminal for the current cursor position by
 // writing a control sequence to the terminal. This call is asynchronous
 // and it returns before any offset has actually been set as the terminal
@@ -517,6 +914,10 @@ func (r *RuneBuffer) getAndSetOffset() {
 		// at the beginning of the next line.
 		r.w.Write([]byte(" \b"))
 	}
+	if r.cfg.AmbiguousWidth == AmbiguousWidthAuto || detectAmbiguousWidth() {
+		r.w.DetectAmbiguousWidth(r.SetOffset)
+		return
+	}
 	r.w.GetOffset(r.SetOffset)
 }
 
@@ -528,6 +929,7 @@ func (r *RuneBuffer) SetOffset(offset string) {
 
 func (r *RuneBuffer) setOffset(offset string) {
 	r.offset = offset
+	r.liveRows = 0 // drawn live lines are left where they are
 	tWidth, _ := r.w.GetWidthHeight()
 	if _, c, ok := (&escapeKeyPair{attr: offset}).Get2(); ok && c > 0 && c < tWidth {
 		r.ppos = c - 1 // c should be 1..tWidth
@@ -580,12 +982,294 @@ func (r *RuneBuffer) Print() {
 }
 
 func (r *RuneBuffer) print() {
+	r.updatePrompt()
+	r.updateStatus()
+	r.printLive()
 	r.w.Write(r.output())
+	r.drawn = r.renderState()
+}
+
+// printLive draws the live lines above the prompt, each cut to fit the
+// terminal width, and moves the prompt below them.
+func (r *RuneBuffer) printLive() {
+	tWidth, _ := r.w.GetWidthHeight()
+	if len(r.live) == 0 || r.done || tWidth == 0 {
+		return
+	}
+	buf := bytes.NewBuffer(nil)
+	r.liveRows, r.liveCol = len(r.live), r.ppos
+	if r.ppos > 0 {
+		buf.WriteString("\n") // below output not ending with a newline
+		r.liveRows++
+	}
+	for _, line := range r.live {
+		line = strings.SplitN(line, "\n", 2)[0]
+		buf.WriteString(string(cutWidth([]rune(line), tWidth-1)))
+		buf.WriteString("\033[K\n")
+	}
+	r.ppos = 0
+	r.w.Write(buf.Bytes())
+}
+
+// SetLive sets the lines drawn above the prompt, they are drawn with the
+// next redraw.
+func (r *RuneBuffer) SetLive(lines []string) {
+	r.Lock()
+	r.live = lines
+	r.Unlock()
+}
+
+func (r *RuneBuffer) updatePrompt() {
+	if r.cfg.PromptFunc != nil && r.transient == nil {
+		r.prompt = []rune(r.cfg.PromptFunc())
+	}
+}
+
+// updateStatus evaluates Config.StatusFunc, each line of the status is cut
+// to fit the terminal width.
+func (r *RuneBuffer) updateStatus() {
+	r.status = nil
+	if r.cfg.StatusFunc == nil || r.done {
+		return
+	}
+	status := r.cfg.StatusFunc(runes.Copy(r.buf), r.idx)
+	if status == "" {
+		return
+	}
+	tWidth, _ := r.w.GetWidthHeight()
+	for _, line := range strings.Split(status, "\n") {
+		r.status = append(r.status, string(cutWidth([]rune(line), tWidth-1)))
+	}
+}
+
+// cutWidth returns the start of rs taking up at most width cells, escape
+// sequences are kept and reset at the end.
+func cutWidth(rs []rune, width int) []rune {
+	cut := make([]rune, 0, len(rs))
+	w, escaped := 0, false
+	for i := 0; i < len(rs); i++ {
+		if rs[i] == '\033' {
+			n := escapeSeqLen(rs[i+1:])
+			cut = append(cut, rs[i:i+1+n]...)
+			i += n
+			escaped = true
+			continue
+		}
+		w += runes.Width(rs[i])
+		if w > width {
+			break
+		}
+		cut = append(cut, rs[i])
+	}
+	if escaped {
+		cut = append(cut, []rune("\033[0m")...)
+	}
+	return cut
+}
+
+// writeStatus writes the status lines below the text, the cursor is
+// assumed to be on the last line of the text and is moved back to it.
+func (r *RuneBuffer) writeStatus(buf *bytes.Buffer) {
+	for _, line := range r.status {
+		buf.WriteString("\n")
+		buf.WriteString(line)
+		buf.WriteString("\033[K")
+	}
+	if len(r.status) > 0 {
+		fmt.Fprintf(buf, "\033[%dA", len(r.status))
+	}
+}
+
+// renderState is what was last drawn of the line, used by printDiff.
+type renderState struct {
+	prompt  []rune
+	ppos    int
+	width   int
+	text    []rune  // buffer and suggestion
+	styles  []Style // style of each rune in text
+	rprompt bool    // right prompt is shown
+	status  string  // status lines
+	writes  uint64  // terminal writes after drawing
+}
+
+// renderText returns the text drawn after the prompt and its styles, ok is
+// false if it can't be known rune by rune because of masking or a Painter.
+func (r *RuneBuffer) renderText() (text []rune, styles []Style, ok bool) {
+	if r.cfg.EnableMask {
+		return nil, nil, false
+	}
+	styles, ok = r.bufStyles()
+	if !ok {
+		return nil, nil, false
+	}
+	text = runes.Copy(r.buf)
+	if r.showSuggestion() {
+		text = append(text, r.suggestion...)
+		for range r.suggestion {
+			styles = append(styles, Style{Dim: true})
+		}
+	}
+	return text, styles, true
+}
+
+// bufStyles returns the style of each rune in the buffer, from the
+// StyledPainter, SetStyle and the bracket highlighting. ok is false if the
+// buffer is drawn by a Painter changing the text.
+func (r *RuneBuffer) bufStyles() (styles []Style, ok bool) {
+	var spans []Span
+	if r.cfg.StyledPainter != nil {
+		spans = r.cfg.StyledPainter.PaintSpans(runes.Copy(r.buf), r.idx)
+	} else if _, ok := r.cfg.Painter.(*defaultPainter); !ok {
+		return nil, false
+	}
+	styles = lineStyles(len(r.buf), spans)
+	for i := r.mark.Start; i < r.mark.End && i < len(styles); i++ {
+		styles[i] = styles[i].over(r.mark.Style)
+	}
+	if i := r.matchingBracket(); i >= 0 {
+		style := r.cfg.MatchingBracketStyle
+		if style == (Style{}) {
+			style = Style{Reverse: true}
+		}
+		styles[i] = styles[i].over(style)
+	}
+	return styles, true
+}
+
+// matchingBracket returns the index of the bracket to highlight, or -1.
+// It matches the bracket at the cursor, or else a closing bracket before
+// it.
+func (r *RuneBuffer) matchingBracket() int {
+	if r.blinking && r.blinkIdx < len(r.buf) &&
+		strings.ContainsRune("([{", r.buf[r.blinkIdx]) {
+		return r.blinkIdx
+	}
+	if !r.cfg.EnableBracketHighlight {
+		return -1
+	}
+	if i := matchBracket(r.buf, r.idx); i >= 0 {
+		return i
+	}
+	if r.idx > 0 && strings.ContainsRune(")]}", r.buf[r.idx-1]) {
+		return matchBracket(r.buf, r.idx-1)
+	}
+	return -1
+}
+
+// BlinkMatch highlights the opening bracket matching the closing bracket
+// before the cursor until EndBlink is called with the returned id, see
+// Config.EnableBlinkMatchingParen. ok is false if there is no match.
+func (r *RuneBuffer) BlinkMatch() (id int, ok bool) {
+	r.Lock()
+	defer r.Unlock()
+	if r.idx == 0 || !strings.ContainsRune(")]}", r.buf[r.idx-1]) {
+		return 0, false
+	}
+	i := matchBracket(r.buf, r.idx-1)
+	if i < 0 {
+		return 0, false
+	}
+	r.redraw(func() {
+		r.blinking, r.blinkIdx = true, i
+		r.blinkID++
+	})
+	return r.blinkID, true
+}
+
+// EndBlink ends the highlighting started by BlinkMatch returning id, it
+// returns true if the line was redrawn.
+func (r *RuneBuffer) EndBlink(id int) bool {
+	r.Lock()
+	defer r.Unlock()
+	if !r.blinking || r.blinkID != id {
+		return false
+	}
+	r.redraw(func() {
+		r.blinking = false
+	})
+	return r.interactive
+}
+
+func (r *RuneBuffer) renderState() *renderState {
+	text, styles, ok := r.renderText()
+	if !ok || isWindows {
+		return nil
+	}
+	tWidth, _ := r.w.GetWidthHeight()
+	return &renderState{
+		prompt:  r.prompt,
+		ppos:    r.ppos,
+		width:   tWidth,
+		text:    text,
+		styles:  styles,
+		rprompt: len(r.rightPrompt()) > 0,
+		status:  strings.Join(r.status, "\n"),
+		writes:  r.w.writeCount(),
+	}
+}
+
+// drawnValid returns true if the screen still shows the last drawn state.
+func (r *RuneBuffer) drawnValid() bool {
+	if r.drawn == nil || r.drawn.writes != r.w.writeCount() {
+		return false
+	}
+	tWidth, _ := r.w.GetWidthHeight()
+	return tWidth > 0 && r.drawn.width == tWidth && r.drawn.ppos == r.ppos
+}
+
+// printDiff updates the drawn line to the current state by redrawing it
+// from the first changed rune, the cursor is on line idxLine. It falls back
+// to erasing and drawing everything if the prompt changed.
+func (r *RuneBuffer) printDiff(idxLine int) {
+	prev := r.drawn
+	r.updatePrompt()
+	r.updateStatus()
+	cur := r.renderState()
+	if cur == nil || !runes.Equal(cur.prompt, prev.prompt) ||
+		(prev.rprompt && !cur.rprompt) {
+		r.cleanWithIdxLine(idxLine)
+		r.print()
+		return
+	}
+
+	d := 0
+	for d < len(cur.text) && d < len(prev.text) &&
+		cur.text[d] == prev.text[d] && cur.styles[d] == prev.styles[d] {
+		d++
+	}
+	if d < len(cur.text) {
+		// a combining rune changes the cluster it is added to
+		d = graphemePrev(cur.text, d+1)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if d == len(cur.text) && d == len(prev.text) && cur.status == prev.status {
+		// only the cursor moved
+		r.moveCursor(buf, cur.text, idxLine, r.idx)
+	} else {
+		r.moveCursor(buf, cur.text, idxLine, d)
+		r.writeText(buf, cur.text[d:], cur.styles[d:], d)
+		if r.isInLineEdge() {
+			buf.WriteString(" \b")
+		}
+		if d < len(prev.text) || prev.status != "" {
+			// clear what is left of the old text and status
+			buf.WriteString("\033[J")
+		}
+		buf.Write(r.rightPrompt())
+		r.writeStatus(buf)
+		if len(cur.text) > r.idx || len(r.status) > 0 {
+			buf.Write(r.getBackspaceSequence())
+		}
+	}
+	r.w.Write(buf.Bytes())
+	cur.writes = r.w.writeCount()
+	r.drawn = cur
 }
 
 func (r *RuneBuffer) output() []byte {
 	buf := bytes.NewBuffer(nil)
-	buf.WriteString(string(r.prompt))
+	buf.WriteString(string(runes.StripPromptMarkers(r.prompt)))
 	if r.cfg.EnableMask && len(r.buf) > 0 {
 		if r.cfg.MaskRune != 0 {
 			buf.WriteString(strings.Repeat(string(r.cfg.MaskRune), len(r.buf)-1))
@@ -595,28 +1279,73 @@ func (r *RuneBuffer) output() []byte {
 		} else if r.cfg.MaskRune != 0 {
 			buf.WriteRune(r.cfg.MaskRune)
 		}
+	} else if styles, ok := r.bufStyles(); ok {
+		r.writeText(buf, r.buf, styles, 0)
 	} else {
-		for _, e := range r.cfg.Painter.Paint(r.buf, r.idx) {
-			if e == '\t' {
-				buf.WriteString(strings.Repeat(" ", TabWidth))
-			} else {
-				buf.WriteRune(e)
-			}
-		}
+		r.writeText(buf, r.cfg.Painter.Paint(r.buf, r.idx), nil, 0)
+	}
+	if r.showSuggestion() {
+		suggestStyle := lineStyles(len(r.suggestion), []Span{
+			{0, len(r.suggestion), Style{Dim: true}},
+		})
+		writeStyled(buf, r.suggestion, suggestStyle)
 	}
 	if r.isInLineEdge() {
 		buf.WriteString(" \b")
 	}
+	buf.Write(r.rightPrompt())
+	r.writeStatus(buf)
 	// cursor position
-	if len(r.buf) > r.idx {
+	shown := r.shownRunes()
+	if len(shown) > r.idx || len(r.status) > 0 {
 		buf.Write(r.getBackspaceSequence())
 	}
 	return buf.Bytes()
 }
 
+// rightPrompt returns the sequence drawing the right prompt at the end of
+// the line the input starts on, or nil if it would collide with the text.
+// The cursor is assumed to be after the text and is restored.
+func (r *RuneBuffer) rightPrompt() []byte {
+	if len(r.rprompt) == 0 {
+		return nil
+	}
+	tWidth, _ := r.w.GetWidthHeight()
+	rw := runes.WidthAll(runes.ColorFilter(r.rprompt))
+	sp := r.getSplitByLine(r.shownRunes(), 1)
+	k := r.promptLineIdx(tWidth)
+	if k >= len(sp) {
+		return nil
+	}
+	line := sp[k]
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+	used := runes.WidthAll(line)
+	if k == 0 {
+		used += r.ppos
+	}
+	// keep at least one space between the text and the right prompt
+	if used+1+rw > tWidth {
+		return nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("\0337") // save cursor
+	if up := len(sp) - 1 - k; up > 0 {
+		fmt.Fprintf(buf, "\033[%dA", up)
+	}
+	fmt.Fprintf(buf, "\033[%dG", tWidth-rw+1)
+	buf.WriteString(string(runes.StripPromptMarkers(r.rprompt)))
+	buf.WriteString("\0338") // restore cursor
+	return buf.Bytes()
+}
+
 func (r *RuneBuffer) getBackspaceSequence() []byte {
-	bcnt := len(r.buf) - r.idx // backwards count to index
-	sp := r.getSplitByLine(r.buf, 1)
+	shown := r.shownRunes()
+	// backwards count to index
+	bcnt := len(r.displayRunes(shown)) - len(r.displayRunes(shown[:r.idx]))
+	sp := r.getSplitByLine(shown, 1)
 
 	// Calculate how many lines up to the index line
 	up := 0
@@ -635,11 +1364,11 @@ func (r *RuneBuffer) getBackspaceSequence() []byte {
 	if spi == 0 {
 		column += r.ppos
 	}
-	for _, rune := range sp[spi] {
+	for _, w := range graphemeWidths(sp[spi]) {
 		if bcnt >= 0 {
 			break
 		}
-		column += runes.Width(rune)
+		column += w
 		bcnt++
 	}
 
@@ -656,9 +1385,107 @@ func (r *RuneBuffer) Reset() []rune {
 	ret := runes.Copy(r.buf)
 	r.buf = r.buf[:0]
 	r.idx = 0
+	r.suggestion = nil
+	r.done = false
+	r.mark = Span{}
+	r.blinking = false
+	if r.transient != nil {
+		r.prompt, r.rprompt = r.transient.prompt, r.transient.rprompt
+		r.transient = nil
+	}
 	return ret
 }
 
+// Transient redraws the line with prompt in place of the prompt and without
+// the right prompt and suggestion, used for the finished line. The prompts
+// are restored by Reset.
+func (r *RuneBuffer) Transient(prompt string) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.interactive {
+		return
+	}
+	if r.transient == nil {
+		r.transient = &runeBufferPrompts{r.prompt, r.rprompt}
+	}
+	r.refresh(func() {
+		r.prompt = []rune(prompt)
+		r.rprompt = nil
+		r.suggestion = nil
+	})
+}
+
+// suggesting returns true if suggestions are enabled and drawn.
+func (r *RuneBuffer) suggesting() bool {
+	return r.interactive && r.cfg.EnableAutoSuggest && !r.cfg.EnableMask
+}
+
+// showSuggestion returns true if the suggestion is shown, which is only
+// done with the cursor at the end of the line.
+func (r *RuneBuffer) showSuggestion() bool {
+	return r.suggesting() && len(r.suggestion) > 0 && r.idx == len(r.buf)
+}
+
+// shownRunes returns the buffer followed by the suggestion if shown.
+func (r *RuneBuffer) shownRunes() []rune {
+	if !r.showSuggestion() {
+		return r.buf
+	}
+	shown := make([]rune, 0, len(r.buf)+len(r.suggestion))
+	shown = append(shown, r.buf...)
+	return append(shown, r.suggestion...)
+}
+
+func (r *RuneBuffer) updateSuggestion() {
+	r.suggestion = nil
+	if !r.suggesting() || len(r.buf) == 0 {
+		return
+	}
+	var s []rune
+	if r.cfg.Suggester != nil {
+		s = r.cfg.Suggester.Suggest(runes.Copy(r.buf))
+	} else if r.HistorySuggest != nil {
+		s = r.HistorySuggest(r.buf)
+	}
+	// only the rest of the current line is shown
+	if i := runes.Index('\n', s); i >= 0 {
+		s = s[:i]
+	}
+	r.suggestion = s
+}
+
+// AcceptSuggestion appends the shown suggestion, or only its first word,
+// to the buffer. It returns false if no suggestion is shown.
+func (r *RuneBuffer) AcceptSuggestion(word bool) bool {
+	r.Lock()
+	defer r.Unlock()
+	if !r.showSuggestion() {
+		return false
+	}
+	r.redraw(func() {
+		n := len(r.suggestion)
+		if word {
+			n = nextWordEnd(r.suggestion)
+		}
+		r.buf = append(r.buf, r.suggestion[:n]...)
+		r.idx = len(r.buf)
+	})
+	return true
+}
+
+// nextWordEnd returns the index after the first word in rs, including
+// word breaks before it.
+func nextWordEnd(rs []rune) int {
+	i := 0
+	for i < len(rs) && IsWordBreak(rs[i]) {
+		i++
+	}
+	for i < len(rs) && !IsWordBreak(rs[i]) {
+		i++
+	}
+	return i
+}
+
 func (r *RuneBuffer) calWidth(m int) int {
 	if m > 0 {
 		return runes.WidthAll(r.buf[r.idx : r.idx+m])
@@ -666,26 +1493,20 @@ func (r *RuneBuffer) calWidth(m int) int {
 	return runes.WidthAll(r.buf[r.idx+m : r.idx])
 }
 
+// SetStyle shows the runes start to end in style, SGR parameters such as
+// "4" for underline, until it's called again or the buffer is reset. An
+// empty range removes the style.
 func (r *RuneBuffer) SetStyle(start, end int, style string) {
 	if end < start {
 		panic("end < start")
 	}
-
-	// goto start
-	move := start - r.idx
-	if move > 0 {
-		r.w.Write([]byte(string(r.buf[r.idx : r.idx+move])))
-	} else {
-		r.w.Write(bytes.Repeat([]byte("\b"), r.calWidth(move)))
-	}
-	r.w.Write([]byte("\033[" + style + "m"))
-	r.w.Write([]byte(string(r.buf[start:end])))
-	r.w.Write([]byte("\033[0m"))
-	// TODO: move back
+	r.update(func() {
+		r.mark = Span{start, end, parseSGR(style)}
+	})
 }
 
 func (r *RuneBuffer) SetWithIdx(idx int, buf []rune) {
-	r.Refresh(func() {
+	r.update(func() {
 		r.buf = buf
 		r.idx = idx
 	})
@@ -701,6 +1522,19 @@ func (r *RuneBuffer) SetPrompt(prompt string) {
 	r.Unlock()
 }
 
+func (r *RuneBuffer) SetRightPrompt(prompt string) {
+	r.Lock()
+	r.rprompt = []rune(prompt)
+	r.Unlock()
+}
+
+// HasRightPrompt returns true if a right prompt is set.
+func (r *RuneBuffer) HasRightPrompt() bool {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.rprompt) > 0
+}
+
 func (r *RuneBuffer) cleanOutput(w io.Writer, idxLine int) {
 	buf := bufio.NewWriter(w)
 
@@ -709,6 +1543,11 @@ func (r *RuneBuffer) cleanOutput(w io.Writer, idxLine int) {
 		buf.WriteString(strings.Repeat("\r\b", len(r.buf)+r.promptLen()))
 		buf.Write([]byte("\033[J"))
 	} else {
+		if r.liveRows > 0 {
+			// clear the live lines too
+			idxLine += r.liveRows
+			r.ppos, r.liveRows = r.liveCol, 0
+		}
 		if idxLine > 0 {
 			fmt.Fprintf(buf, "\033[%dA", idxLine) // move cursor up by idxLine
 		}
@@ -734,5 +1573,6 @@ func (r *RuneBuffer) cleanWithIdxLine(idxLine int) {
 	if !r.interactive {
 		return
 	}
+	r.drawn = nil
 	r.cleanOutput(r.w, idxLine)
 }
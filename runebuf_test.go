@@ -0,0 +1,212 @@
+package readline
+
+import (
+	"testing"
+)
+
+func newTestRuneBuffer(s string) *RuneBuffer {
+	cfg := &Config{
+		KillRingLimit:  10,
+		FuncIsTerminal: func() bool { return false },
+	}
+	r := NewRuneBuffer(nil, "", cfg)
+	r.Set([]rune(s))
+	return r
+}
+
+func TestKillRing(t *testing.T) {
+	r := newTestRuneBuffer("one two three")
+
+	// consecutive backward kills are joined
+	r.StartCommand()
+	r.BackEscapeWord()
+	r.StartCommand()
+	r.BackEscapeWord()
+	testEqual(t, string(r.Runes()), "one ", nil)
+
+	r.StartCommand()
+	r.MoveToLineStart()
+	r.StartCommand()
+	r.Kill()
+	testEqual(t, string(r.Runes()), "", nil)
+
+	r.StartCommand()
+	r.Yank()
+	testEqual(t, string(r.Runes()), "one ", nil)
+	r.StartCommand()
+	testEqual(t, r.YankPop(), true, nil)
+	testEqual(t, string(r.Runes()), "two three", nil)
+	testEqual(t, r.Pos(), len("two three"), nil)
+	r.StartCommand()
+	testEqual(t, r.YankPop(), true, nil)
+	testEqual(t, string(r.Runes()), "one ", nil)
+
+	// yank-pop only works right after a yank
+	r.StartCommand()
+	r.MoveBackward()
+	r.StartCommand()
+	testEqual(t, r.YankPop(), false, nil)
+}
+
+func TestKillRingDelete(t *testing.T) {
+	r := newTestRuneBuffer("hello world")
+
+	r.StartCommand()
+	r.MoveToPrevWord()
+	r.StartCommand()
+	r.Kill()
+	r.StartCommand()
+	r.MoveToLineStart()
+	for i := 0; i < 3; i++ {
+		r.StartCommand()
+		r.Delete()
+	}
+	r.StartCommand()
+	r.MoveToLineEnd()
+	r.StartCommand()
+	r.Yank()
+	testEqual(t, string(r.Runes()), "lo world", nil)
+	testEqual(t, len(r.killRing), 1, nil)
+}
+
+func TestKillRingLimit(t *testing.T) {
+	r := newTestRuneBuffer("")
+	r.cfg.KillRingLimit = 2
+	for _, s := range []string{"a", "b", "c"} {
+		r.StartCommand()
+		r.Set([]rune(s))
+		r.StartCommand()
+		r.KillFront()
+	}
+	testEqual(t, rs(r.killRing), []string{"b", "c"}, nil)
+}
+
+func TestRightPrompt(t *testing.T) {
+	cfg := &Config{
+		FuncIsTerminal: func() bool { return true },
+		Painter:        &defaultPainter{},
+	}
+	r := NewRuneBuffer(&Terminal{cfg: cfg, width: 20}, "> ", cfg)
+	r.SetRightPrompt("main")
+	r.buf = []rune("ls")
+	r.idx = 2
+	testEqual(t, string(r.output()), "> ls\0337\033[17Gmain\0338", nil)
+
+	// hidden when there is no space left between the text and the prompt
+	r.buf = []rune("0123456789abcd")
+	r.idx = len(r.buf)
+	testEqual(t, string(r.output()), "> 0123456789abcd", nil)
+}
+
+func TestGraphemeEditing(t *testing.T) {
+	family := "\U0001F468‍\U0001F469‍\U0001F467"
+	r := newTestRuneBuffer("a" + family + "e\u0301")
+	r.Backspace()
+	testEqual(t, string(r.Runes()), "a"+family, nil)
+	r.MoveBackward()
+	testEqual(t, r.Pos(), 1, nil)
+	r.Transpose()
+	testEqual(t, string(r.Runes()), family+"a", nil)
+	r.MoveToLineStart()
+	r.MoveForward()
+	testEqual(t, r.Pos(), len([]rune(family)), nil)
+	r.MoveToLineStart()
+	r.Delete()
+	testEqual(t, string(r.Runes()), "a", nil)
+}
+
+func TestEditRules(t *testing.T) {
+	r := newTestRuneBuffer("")
+	r.cfg.EditRules = &BracketEditRules{IndentUnit: "  "}
+	for _, ch := range "f(a" {
+		r.Type(ch)
+	}
+	testEqual(t, string(r.Runes()), "f(a)", nil)
+	r.Type(')')
+	testEqual(t, string(r.Runes()), "f(a)", nil)
+	testEqual(t, r.Pos(), 4, nil)
+
+	// no pair before a word or for a quote after a word
+	r.Set([]rune("x"))
+	r.MoveToLineStart()
+	r.Type('(')
+	testEqual(t, string(r.Runes()), "(x", nil)
+	r.Set([]rune("a"))
+	r.Type('"')
+	testEqual(t, string(r.Runes()), "a\"", nil)
+
+	r.Set([]rune("["))
+	r.Type(']')
+	r.MoveBackward()
+	r.Backspace()
+	testEqual(t, string(r.Runes()), "", nil)
+
+	r.Set([]rune("  if {"))
+	r.Type('}')
+	r.MoveBackward()
+	r.NewLine()
+	testEqual(t, string(r.Runes()), "  if {\n    \n  }", nil)
+	testEqual(t, r.Pos(), 11, nil)
+	r.MoveToLineEnd()
+	r.NewLine()
+	testEqual(t, string(r.Runes()), "  if {\n    \n  }\n  ", nil)
+}
+
+func TestMatchBracket(t *testing.T) {
+	tests := []struct {
+		line string
+		i    int
+		want int
+	}{
+		{"f(a[1], {b})", 1, 11},
+		{"f(a[1], {b})", 11, 1},
+		{"f(a[1], {b})", 5, 3},
+		{"f(a[1], {b})", 10, 8},
+		{"f(a", 1, -1},
+		{"(a]", 0, -1},
+		{"(a]", 2, -1},
+		{`(")", b)`, 0, 7},
+		{`(')', b)`, 7, 0},
+		{`(\), b)`, 0, 6},
+		{`(\), b)`, 2, -1},
+		{`"(" ()`, 1, -1},
+		{"a", 0, -1},
+	}
+	for _, tt := range tests {
+		if got := matchBracket([]rune(tt.line), tt.i); got != tt.want {
+			t.Errorf("%q at %d: got %d, expected %d", tt.line, tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestBracketHighlight(t *testing.T) {
+	r := newTestRuneBuffer("f(a, b)")
+	r.cfg.Painter = &defaultPainter{}
+	r.cfg.EnableBracketHighlight = true
+	r.cfg.MatchingBracketStyle = Style{Fg: ColorRed}
+	styles, _ := r.bufStyles()
+	testEqual(t, styles[1], Style{Fg: ColorRed}, nil)
+	r.MoveToLineStart()
+	styles, _ = r.bufStyles()
+	testEqual(t, styles, make([]Style, 7), nil)
+
+	// combined with the search match style
+	r.MoveForward()
+	r.SetStyle(0, 3, "4")
+	styles, _ = r.bufStyles()
+	testEqual(t, styles[:3], []Style{{Underline: true}, {Underline: true}, {Underline: true}}, nil)
+	testEqual(t, styles[6], Style{Fg: ColorRed}, nil)
+
+	r.cfg.EnableBracketHighlight = false
+	r.SetStyle(0, 0, "")
+	id, ok := r.BlinkMatch()
+	testEqual(t, ok, false, nil)
+	r.MoveToLineEnd()
+	id, ok = r.BlinkMatch()
+	testEqual(t, ok, true, nil)
+	styles, _ = r.bufStyles()
+	testEqual(t, styles[1], Style{Fg: ColorRed}, nil)
+	r.EndBlink(id)
+	styles, _ = r.bufStyles()
+	testEqual(t, styles[1], Style{}, nil)
+}
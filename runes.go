@@ -2,6 +2,7 @@ package readline
 
 import (
 	"bytes"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 	"golang.org/x/text/width"
@@ -114,15 +115,30 @@ func (Runes) Index(r rune, rs []rune) int {
 	return -1
 }
 
+// Non-printing markers, text between them is not counted when measuring a
+// prompt, like RL_PROMPT_START_IGNORE and RL_PROMPT_END_IGNORE in bash.
+const (
+	PromptStartIgnore = '\001'
+	PromptEndIgnore   = '\002'
+)
+
+// ColorFilter removes escape sequences and text between PromptStartIgnore
+// and PromptEndIgnore, leaving the runes taking up space on the screen.
 func (Runes) ColorFilter(r []rune) []rune {
 	newr := make([]rune, 0, len(r))
 	for pos := 0; pos < len(r); pos++ {
-		if r[pos] == '\033' && r[pos+1] == '[' {
-			idx := runes.Index('m', r[pos+2:])
+		switch r[pos] {
+		case PromptStartIgnore:
+			idx := runes.Index(PromptEndIgnore, r[pos+1:])
 			if idx == -1 {
-				continue
+				return newr
 			}
-			pos += idx + 2
+			pos += idx + 1
+			continue
+		case PromptEndIgnore:
+			continue
+		case '\033':
+			pos += escapeSeqLen(r[pos+1:])
 			continue
 		}
 		newr = append(newr, r[pos])
@@ -130,6 +146,53 @@ func (Runes) ColorFilter(r []rune) []rune {
 	return newr
 }
 
+// escapeSeqLen returns the length of the escape sequence following Esc in
+// r, an unterminated sequence uses all of r.
+func escapeSeqLen(r []rune) int {
+	if len(r) == 0 {
+		return 0
+	}
+	switch r[0] {
+	case '[':
+		// CSI, parameter and intermediate bytes up to a final byte
+		for i := 1; i < len(r); i++ {
+			if r[i] >= 0x40 && r[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']', 'P', 'X', '^', '_':
+		// OSC, DCS, SOS, PM and APC, terminated by BEL or ST
+		for i := 1; i < len(r); i++ {
+			if r[i] == CharBell && r[0] == ']' {
+				return i + 1
+			}
+			if r[i] == '\033' && i+1 < len(r) && r[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	default:
+		// intermediate bytes up to a final byte, as in "\033(B"
+		for i := 0; i < len(r); i++ {
+			if r[i] >= 0x30 && r[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	}
+	return len(r)
+}
+
+// StripPromptMarkers removes PromptStartIgnore and PromptEndIgnore from r
+// but keeps the text between them.
+func (Runes) StripPromptMarkers(r []rune) []rune {
+	newr := make([]rune, 0, len(r))
+	for _, e := range r {
+		if e != PromptStartIgnore && e != PromptEndIgnore {
+			newr = append(newr, e)
+		}
+	}
+	return newr
+}
+
 var zeroWidth = []*unicode.RangeTable{
 	unicode.Mn,
 	unicode.Me,
@@ -144,6 +207,29 @@ var doubleWidth = []*unicode.RangeTable{
 	unicode.Katakana,
 }
 
+// AmbiguousWidthAuto is used as Config.AmbiguousWidth or with
+// SetAmbiguousWidth to detect the width of East Asian ambiguous characters
+// from the terminal.
+const AmbiguousWidthAuto = -1
+
+var ambiguousWidth int32 = 1
+
+// SetAmbiguousWidth sets the width, 1 or 2, used for characters of East
+// Asian ambiguous width such as ± and ①, it defaults to 1. The width is
+// shared by all instances like the rest of the width functions, it's also
+// set by Config.AmbiguousWidth.
+// AmbiguousWidthAuto measures it by printing a probe character when the
+// next prompt is shown, until then 1 is used.
+func SetAmbiguousWidth(w int) {
+	atomic.StoreInt32(&ambiguousWidth, int32(w))
+}
+
+// detectAmbiguousWidth reports if SetAmbiguousWidth was called with
+// AmbiguousWidthAuto and the width isn't detected yet.
+func detectAmbiguousWidth() bool {
+	return atomic.LoadInt32(&ambiguousWidth) == AmbiguousWidthAuto
+}
+
 func (Runes) Width(r rune) int {
 	if r == '\t' {
 		return TabWidth
@@ -154,14 +240,22 @@ func (Runes) Width(r rune) int {
 	switch width.LookupRune(r).Kind() {
 	case width.EastAsianWide, width.EastAsianFullwidth:
 		return 2
-	default:
+	case width.EastAsianAmbiguous:
+		if atomic.LoadInt32(&ambiguousWidth) == 2 {
+			return 2
+		}
 		return 1
 	}
+	return 1
 }
 
+// WidthAll returns the number of cells r takes up, grapheme clusters such
+// as emoji sequences are counted as one character.
 func (Runes) WidthAll(r []rune) (length int) {
-	for i := 0; i < len(r); i++ {
-		length += runes.Width(r[i])
+	for i := 0; i < len(r); {
+		n := graphemeLen(r[i:])
+		length += graphemeWidth(r[i : i+n])
+		i += n
 	}
 	return
 }
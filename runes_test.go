@@ -28,6 +28,8 @@ func TestSingleRuneWidth(t *testing.T) {
 		{'Ｗ', 2},          // full-width romanji
 		{'）', 2},          // full-width symbols
 		{'😅', 2},          // emoji
+		{'🛕', 2},          // emoji from Unicode 12
+		{'🩰', 2},          // emoji from Unicode 12
         }
 
         for _, test := range tests {
@@ -60,6 +62,25 @@ func TestRuneWidth(t *testing.T) {
 	}
 }
 
+func TestColorFilter(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"\033[1;31mred\033[0m", "red"},
+		{"\033]8;;http://a.b\033\\link\033]8;;\033\\", "link"},
+		{"\033]0;title\007> ", "> "},
+		{"\033[2K\033[?25h\033(Bx", "x"},
+		{"\001\033[1m\002bold\001\033[0m\002> ", "bold> "},
+		{"a\001hidden", "a"},
+		{"a\033", "a"},
+	}
+	for _, test := range tests {
+		testEqual(t, string(runes.ColorFilter([]rune(test.in))), test.out, nil)
+	}
+	testEqual(t, string(runes.StripPromptMarkers([]rune("\001\033[1m\002a"))), "\033[1ma", nil)
+}
+
 type tagg struct {
 	r      [][]rune
 	e      [][]rune
@@ -102,3 +123,13 @@ func TestAggRunes(t *testing.T) {
 		}
 	}
 }
+
+func TestAmbiguousWidth(t *testing.T) {
+	defer SetAmbiguousWidth(1)
+	testEqual(t, runes.WidthAll([]rune("±①a")), 3, nil)
+	SetAmbiguousWidth(2)
+	testEqual(t, runes.WidthAll([]rune("±①a")), 5, nil)
+	// not detected yet
+	SetAmbiguousWidth(AmbiguousWidthAuto)
+	testEqual(t, runes.WidthAll([]rune("±①a")), 3, nil)
+}
@@ -17,17 +17,15 @@ const (
 )
 
 type opSearch struct {
-	inMode    bool
-	state     int
-	dir       int
-	source    *list.Element
-	w         *Terminal
-	buf       *RuneBuffer
-	data      []rune
-	history   *opHistory
-	cfg       *Config
-	markStart int
-	markEnd   int
+	inMode  bool
+	state   int
+	dir     int
+	source  *list.Element
+	w       *Terminal
+	buf     *RuneBuffer
+	data    []rune
+	history *opHistory
+	cfg     *Config
 }
 
 func newOpSearch(w *Terminal, buf *RuneBuffer, history *opHistory, cfg *Config) *opSearch {
@@ -79,7 +77,7 @@ func (o *opSearch) search(isChange bool) bool {
 		idx += len(o.data)
 	}
 	o.buf.SetWithIdx(idx, item)
-	o.markStart, o.markEnd = start, end
+	o.buf.SetStyle(start, end, "4")
 	o.SearchRefresh(idx)
 	return true
 }
@@ -107,11 +105,11 @@ func (o *opSearch) SearchMode(dir int) bool {
 }
 
 func (o *opSearch) ExitSearchMode(revert bool) {
+	o.buf.SetStyle(0, 0, "")
 	if revert {
 		o.history.current = o.source
 		o.buf.Set(o.history.showItem(o.history.current.Value))
 	}
-	o.markStart, o.markEnd = 0, 0
 	o.state = S_STATE_FOUND
 	o.inMode = false
 	o.source = nil
@@ -132,10 +130,6 @@ func (o *opSearch) SearchRefresh(x int) {
 	x += o.buf.PromptLen()
 	x = x % tWidth
 
-	if o.markStart > 0 {
-		o.buf.SetStyle(o.markStart, o.markEnd, "4")
-	}
-
 	lineCnt := o.buf.CursorLineCount()
 	buf := bytes.NewBuffer(nil)
 	buf.Write(bytes.Repeat([]byte("\n"), lineCnt))
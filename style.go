@@ -0,0 +1,214 @@
+package readline
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Color is a terminal color, the zero value is the terminals default
+// color. ColorBlack to ColorBrightWhite are the 16 basic colors and
+// Color256 returns colors of the 256 color palette.
+type Color int
+
+const (
+	ColorDefault Color = iota
+	ColorBlack
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+	ColorBrightBlack
+	ColorBrightRed
+	ColorBrightGreen
+	ColorBrightYellow
+	ColorBrightBlue
+	ColorBrightMagenta
+	ColorBrightCyan
+	ColorBrightWhite
+)
+
+// Color256 returns color n of the 256 color palette, 0-15 are the same as
+// ColorBlack to ColorBrightWhite.
+func Color256(n uint8) Color {
+	return Color(n) + 1
+}
+
+// sgr appends the SGR parameters of the color, base is 30 for foreground
+// and 40 for background.
+func (c Color) sgr(params []string, base int) []string {
+	n := int(c) - 1
+	switch {
+	case c == ColorDefault:
+		return params
+	case n < 8:
+		return append(params, strconv.Itoa(base+n))
+	case n < 16:
+		return append(params, strconv.Itoa(base+60+n-8))
+	}
+	return append(params, strconv.Itoa(base+8), "5", strconv.Itoa(n))
+}
+
+// Style is how text is shown, the zero value is unstyled text.
+type Style struct {
+	Fg        Color
+	Bg        Color
+	Bold      bool
+	Dim       bool
+	Italic    bool
+	Underline bool
+	Reverse   bool
+}
+
+// SGR returns the escape sequence selecting the style, or "" for the zero
+// style. It does not reset a previous style.
+func (s Style) SGR() string {
+	var params []string
+	for _, a := range []struct {
+		on    bool
+		param string
+	}{
+		{s.Bold, "1"},
+		{s.Dim, "2"},
+		{s.Italic, "3"},
+		{s.Underline, "4"},
+		{s.Reverse, "7"},
+	} {
+		if a.on {
+			params = append(params, a.param)
+		}
+	}
+	params = s.Fg.sgr(params, 30)
+	params = s.Bg.sgr(params, 40)
+	if len(params) == 0 {
+		return ""
+	}
+	return "\033[" + strings.Join(params, ";") + "m"
+}
+
+// Span styles the runes Start to End, End not included, of a line.
+type Span struct {
+	Start int
+	End   int
+	Style Style
+}
+
+// StyledPainter is like Painter but instead of changing the line it
+// returns spans to style, which makes it possible to color text without
+// affecting the cursor position or line wrapping. Later spans override
+// earlier ones where they overlap.
+type StyledPainter interface {
+	PaintSpans(line []rune, pos int) []Span
+}
+
+// StyledPainterFunc adapts a function to the StyledPainter interface.
+type StyledPainterFunc func(line []rune, pos int) []Span
+
+func (f StyledPainterFunc) PaintSpans(line []rune, pos int) []Span {
+	return f(line, pos)
+}
+
+// lineStyles returns the style of each rune in a line of length n.
+func lineStyles(n int, spans []Span) []Style {
+	styles := make([]Style, n)
+	for _, s := range spans {
+		if s.Start < 0 {
+			s.Start = 0
+		}
+		if s.End > n {
+			s.End = n
+		}
+		for i := s.Start; i < s.End; i++ {
+			styles[i] = s.Style
+		}
+	}
+	return styles
+}
+
+// writeStyled writes rs with tabs expanded and each rune in its style.
+func writeStyled(buf *bytes.Buffer, rs []rune, styles []Style) {
+	cur := Style{}
+	for i, e := range rs {
+		if styles != nil && styles[i] != cur {
+			if cur != (Style{}) {
+				buf.WriteString("\033[0m")
+			}
+			cur = styles[i]
+			buf.WriteString(cur.SGR())
+		}
+		if e == '\t' {
+			buf.WriteString(strings.Repeat(" ", TabWidth))
+		} else {
+			buf.WriteRune(e)
+		}
+	}
+	if cur != (Style{}) {
+		buf.WriteString("\033[0m")
+	}
+}
+
+// over returns s with the attributes and colors set in o added.
+func (s Style) over(o Style) Style {
+	if o.Fg != ColorDefault {
+		s.Fg = o.Fg
+	}
+	if o.Bg != ColorDefault {
+		s.Bg = o.Bg
+	}
+	s.Bold = s.Bold || o.Bold
+	s.Dim = s.Dim || o.Dim
+	s.Italic = s.Italic || o.Italic
+	s.Underline = s.Underline || o.Underline
+	s.Reverse = s.Reverse || o.Reverse
+	return s
+}
+
+// parseSGR returns the style selected by SGR parameters such as "1;4",
+// unknown parameters are ignored.
+func parseSGR(params string) Style {
+	var s Style
+	ps := strings.Split(params, ";")
+	for i := 0; i < len(ps); i++ {
+		n, err := strconv.Atoi(ps[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case n == 0:
+			s = Style{}
+		case n == 1:
+			s.Bold = true
+		case n == 2:
+			s.Dim = true
+		case n == 3:
+			s.Italic = true
+		case n == 4:
+			s.Underline = true
+		case n == 7:
+			s.Reverse = true
+		case n >= 30 && n <= 37:
+			s.Fg = Color(n - 30 + 1)
+		case n >= 40 && n <= 47:
+			s.Bg = Color(n - 40 + 1)
+		case n >= 90 && n <= 97:
+			s.Fg = Color(n - 90 + 9)
+		case n >= 100 && n <= 107:
+			s.Bg = Color(n - 100 + 9)
+		case (n == 38 || n == 48) && i+2 < len(ps) && ps[i+1] == "5":
+			c, err := strconv.Atoi(ps[i+2])
+			i += 2
+			if err != nil || c < 0 || c > 255 {
+				continue
+			}
+			if n == 38 {
+				s.Fg = Color256(uint8(c))
+			} else {
+				s.Bg = Color256(uint8(c))
+			}
+		}
+	}
+	return s
+}
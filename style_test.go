@@ -0,0 +1,34 @@
+package readline
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStyleSGR(t *testing.T) {
+	tests := []struct {
+		style Style
+		sgr   string
+	}{
+		{Style{}, ""},
+		{Style{Fg: ColorRed}, "\033[31m"},
+		{Style{Fg: ColorBrightBlue, Bg: ColorBlack}, "\033[94;40m"},
+		{Style{Bold: true, Underline: true, Fg: Color256(208)}, "\033[1;4;38;5;208m"},
+		{Style{Italic: true, Bg: Color256(9)}, "\033[3;101m"},
+	}
+	for _, test := range tests {
+		testEqual(t, test.style.SGR(), test.sgr, nil)
+	}
+}
+
+func TestWriteStyled(t *testing.T) {
+	line := []rune("select 1\tfrom")
+	styles := lineStyles(len(line), []Span{
+		{0, 6, Style{Bold: true}},
+		{9, 20, Style{Bold: true}},
+		{5, 6, Style{Fg: ColorRed}},
+	})
+	buf := bytes.NewBuffer(nil)
+	writeStyled(buf, line, styles)
+	testEqual(t, buf.String(), "\033[1mselec\033[0m\033[31mt\033[0m 1    \033[1mfrom\033[0m", nil)
+}
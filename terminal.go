@@ -7,9 +7,11 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Terminal struct {
+	writes    uint64 // first for 64-bit alignment of atomic access
 	m         sync.Mutex
 	cfg       *Config
 	outchan   chan rune
@@ -22,8 +24,17 @@ type Terminal struct {
 	width     int                 // terminal width
 	height    int                 // terminal height
 	sizeChan  chan string
+
+	kitty     int // kitty keyboard protocol state, guarded by m
+	probed    bool // ambiguous width detected, guarded by m
 }
 
+const (
+	kittyOff     = iota
+	kittyQueried // waiting for the reply to the query
+	kittyOn
+)
+
 func NewTerminal(cfg *Config) (*Terminal, error) {
 	if err := cfg.Init(); err != nil {
 		return nil, err
@@ -38,7 +49,7 @@ func NewTerminal(cfg *Config) (*Terminal, error) {
 		kickChan: make(chan struct{}, 1),
 		outchan:  make(chan rune),
 		stopChan: make(chan struct{}, 1),
-		sizeChan: make(chan string, 1),
+		sizeChan: make(chan string, 2), // room for DetectAmbiguousWidth replies
 	}
 	// Get and cache the current terminal size.
 	t.OnSizeChange()
@@ -62,17 +73,65 @@ func (t *Terminal) SleepToResume() {
 }
 
 func (t *Terminal) EnterRawMode() (err error) {
-	return t.cfg.FuncMakeRaw()
+	if err = t.cfg.FuncMakeRaw(); err != nil {
+		return err
+	}
+	if t.cfg.EnableBracketedPaste && t.cfg.useInteractive() {
+		t.Write([]byte("\033[?2004h"))
+	}
+	if t.cfg.EnableKittyKeyboard && t.cfg.useInteractive() {
+		t.m.Lock()
+		t.kitty = kittyQueried
+		t.m.Unlock()
+		// query the current flags followed by primary device attributes
+		// which all terminals answer, so a lone DA reply means no support
+		t.Write([]byte("\033[?u\033[c"))
+	}
+	return nil
 }
 
 func (t *Terminal) ExitRawMode() (err error) {
+	if t.cfg.EnableBracketedPaste && t.cfg.useInteractive() {
+		t.Write([]byte("\033[?2004l"))
+	}
+	t.m.Lock()
+	if t.kitty == kittyOn {
+		t.Write([]byte("\033[<u"))
+	}
+	t.kitty = kittyOff
+	t.m.Unlock()
 	return t.cfg.FuncExitRaw()
 }
 
+// KittyKeyboard returns true if the kitty keyboard protocol is in use.
+func (t *Terminal) KittyKeyboard() bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.kitty == kittyOn
+}
+
+// kittyReply handles the reply to the keyboard protocol query by
+// enabling disambiguated escape codes.
+func (t *Terminal) kittyReply() {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.kitty == kittyQueried {
+		t.kitty = kittyOn
+		t.Write([]byte("\033[>1u"))
+	}
+}
+
 func (t *Terminal) Write(b []byte) (int, error) {
+	atomic.AddUint64(&t.writes, 1)
 	return t.cfg.Stdout.Write(b)
 }
 
+// writeCount returns the number of writes so far, used to tell if anything
+// was written after drawing the line.
+func (t *Terminal) writeCount() uint64 {
+	return atomic.LoadUint64(&t.writes)
+}
+
 // WriteStdin prefill the next Stdin fetch
 // Next time you call ReadLine() this value will be writen before the user input
 func (t *Terminal) WriteStdin(b []byte) (int, error) {
@@ -91,6 +150,32 @@ func (t *Terminal) GetOffset(f func(offset string)) {
 	SendCursorPosition(t)
 }
 
+// DetectAmbiguousWidth is like GetOffset but also prints a character of
+// East Asian ambiguous width and queries the position again to find how
+// wide the terminal draws it, see SetAmbiguousWidth. It only probes once,
+// later calls are the same as GetOffset.
+func (t *Terminal) DetectAmbiguousWidth(f func(offset string)) {
+	t.m.Lock()
+	probed := t.probed
+	t.probed = true
+	t.m.Unlock()
+	if probed || isWindows {
+		t.GetOffset(f)
+		return
+	}
+	go func() {
+		offset := <-t.sizeChan
+		_, c0, ok0 := (&escapeKeyPair{attr: offset}).Get2()
+		_, c1, ok1 := (&escapeKeyPair{attr: <-t.sizeChan}).Get2()
+		if w := c1 - c0; ok0 && ok1 && (w == 1 || w == 2) {
+			SetAmbiguousWidth(w)
+		}
+		f(offset)
+	}()
+	// print the probe and erase it again
+	t.Write([]byte("\033[6n\0337\u00b1\033[6n\0338\033[K"))
+}
+
 func (t *Terminal) Print(s string) {
 	fmt.Fprintf(t.cfg.Stdout, "%s", s)
 }
@@ -130,10 +215,12 @@ func (t *Terminal) ioloop() {
 		isEscape       bool
 		isEscapeEx     bool
 		isEscapeSS3    bool
+		isPaste        bool
 		expectNextChar bool
 	)
 
-	buf := bufio.NewReader(t.getStdin())
+	in := newKeyseqReader(t.getStdin())
+	buf := bufio.NewReader(in)
 	for {
 		if !expectNextChar {
 			select {
@@ -152,6 +239,22 @@ func (t *Terminal) ioloop() {
 			break
 		}
 
+		if isPaste {
+			// pass pasted text on as is until the end marker
+			if r == CharEsc {
+				if b, err := buf.Peek(5); err == nil && string(b) == "[201~" {
+					buf.Discard(5)
+					isPaste = false
+					r = CharBracketedPasteEnd
+				}
+			}
+			if r != 0 {
+				t.outchan <- r
+			}
+			expectNextChar = true
+			continue
+		}
+
 		if isEscape {
 			isEscape = false
 			if r == CharEscapeEx {
@@ -165,11 +268,47 @@ func (t *Terminal) ioloop() {
 				isEscapeSS3 = true
 				continue
 			}
-			r = escapeKey(r, buf)
+			if t.cfg.VimMode && !t.KittyKeyboard() {
+				// Esc followed by a key typed quickly
+				t.outchan <- CharEsc
+			} else if k := escapeKey(r, buf); k != r {
+				r = k
+			} else {
+				// unknown Meta key, pass Esc on so the sequence
+				// can be bound in the keymap.
+				t.outchan <- CharEsc
+			}
 		} else if isEscapeEx {
 			isEscapeEx = false
 			if key := readEscKey(r, buf); key != nil {
-				r = escapeExKey(key)
+				if strings.HasPrefix(key.attr, "?") {
+					// reply to a query, Esc[?<flags>u means the
+					// kitty keyboard protocol is supported
+					if key.typ == 'u' {
+						t.kittyReply()
+					}
+					expectNextChar = true
+					continue
+				}
+				if key.typ == 'u' {
+					k, meta := escapeKittyKey(key)
+					if k == CharEsc && !meta && t.cfg.VimMode {
+						t.outchan <- k
+						expectNextChar = true
+						continue
+					}
+					if k != 0 && meta {
+						if m, ok := metaKeys[k]; ok {
+							k = m
+						} else {
+							t.outchan <- CharEsc
+						}
+					}
+					r = k
+				} else {
+					r = escapeExKey(key)
+				}
+				isPaste = r == CharBracketedPasteStart
 				// offset
 				if key.typ == 'R' {
 					if _, _, ok := key.Get2(); ok {
@@ -200,12 +339,25 @@ func (t *Terminal) ioloop() {
 		expectNextChar = true
 		switch r {
 		case CharEsc:
-			if t.cfg.VimMode {
+			// with the kitty protocol the Esc key is reported as an
+			// escape sequence, so Esc always starts one
+			if t.KittyKeyboard() {
+				isEscape = true
+				break
+			}
+			timeout := t.cfg.EscapeTimeout
+			if timeout < 0 {
+				if t.cfg.VimMode {
+					t.outchan <- r
+					break
+				}
+			} else if buf.Buffered() == 0 && !in.wait(timeout) {
+				// nothing followed in time, a bare Esc
 				t.outchan <- r
 				break
 			}
 			isEscape = true
-		case CharInterrupt, CharEnter, CharCtrlJ, CharDelete, CharEOT:
+		case CharInterrupt, CharEnter, KeyCtrlM, CharCtrlJ, CharDelete, CharEOT:
 			expectNextChar = false
 			fallthrough
 		default:
@@ -267,3 +419,75 @@ func (t *Terminal) GetWidthHeight() (width, height int) {
 	defer t.m.Unlock()
 	return t.width, t.height
 }
+
+// keyseqReader reads from r in a goroutine when asked to, so that waiting
+// for the rest of an escape sequence can time out. It is only used by the
+// ioloop goroutine.
+type keyseqReader struct {
+	r       io.Reader
+	result  chan keyseqRead
+	reading bool
+	buf     []byte
+	err     error
+}
+
+type keyseqRead struct {
+	data []byte
+	err  error
+}
+
+func newKeyseqReader(r io.Reader) *keyseqReader {
+	return &keyseqReader{
+		r:      r,
+		result: make(chan keyseqRead, 1),
+	}
+}
+
+func (k *keyseqReader) start() {
+	if k.reading {
+		return
+	}
+	k.reading = true
+	go func() {
+		b := make([]byte, 1024)
+		n, err := k.r.Read(b)
+		k.result <- keyseqRead{b[:n], err}
+	}()
+}
+
+func (k *keyseqReader) receive(res keyseqRead) {
+	k.reading = false
+	k.buf, k.err = res.data, res.err
+}
+
+// wait returns true if input is available within d.
+func (k *keyseqReader) wait(d time.Duration) bool {
+	if len(k.buf) > 0 || k.err != nil {
+		return true
+	}
+	k.start()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case res := <-k.result:
+		k.receive(res)
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (k *keyseqReader) Read(p []byte) (int, error) {
+	if len(k.buf) == 0 && k.err == nil {
+		k.start()
+		k.receive(<-k.result)
+	}
+	n := copy(p, k.buf)
+	k.buf = k.buf[n:]
+	if len(k.buf) == 0 && k.err != nil {
+		err := k.err
+		k.err = nil
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,209 @@
+package readline
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// testScreen is a minimal terminal emulator used to check what the user
+// would see. It handles printing with auto wrap, \r, \n (as \r\n), \b and
+// the cursor movement and erase sequences used by RuneBuffer, other
+// escape sequences are ignored. The screen grows downwards instead of
+// scrolling.
+type testScreen struct {
+	m       sync.Mutex
+	width   int
+	lines   [][]rune
+	x, y    int
+	wrap    bool // cursor is past the last column
+	saved   [2]int
+	pending []byte
+	written int // bytes written
+}
+
+func newTestScreen(width int) *testScreen {
+	return &testScreen{width: width, lines: [][]rune{nil}}
+}
+
+func (s *testScreen) line(y int) []rune {
+	for len(s.lines) <= y {
+		s.lines = append(s.lines, nil)
+	}
+	for len(s.lines[y]) < s.width {
+		s.lines[y] = append(s.lines[y], ' ')
+	}
+	return s.lines[y]
+}
+
+func (s *testScreen) Write(b []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.written += len(b)
+	data := append(s.pending, b...)
+	s.pending = nil
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			s.pending = append([]byte{}, data...)
+			break
+		}
+		r, n := utf8.DecodeRune(data)
+		if r == '\033' {
+			m := s.escape(data)
+			if m == 0 {
+				s.pending = append([]byte{}, data...)
+				break
+			}
+			data = data[m:]
+			continue
+		}
+		data = data[n:]
+		s.put(r)
+	}
+	return len(b), nil
+}
+
+func (s *testScreen) put(r rune) {
+	switch r {
+	case '\r':
+		s.x, s.wrap = 0, false
+	case '\n':
+		s.x, s.y, s.wrap = 0, s.y+1, false
+		s.line(s.y)
+	case '\b':
+		if s.wrap {
+			s.wrap = false
+		} else if s.x > 0 {
+			s.x--
+		}
+	case '\a':
+	default:
+		if s.wrap {
+			s.x, s.y, s.wrap = 0, s.y+1, false
+		}
+		w := runes.Width(r)
+		if w == 0 {
+			return
+		}
+		if s.x+w > s.width {
+			s.x, s.y = 0, s.y+1
+		}
+		l := s.line(s.y)
+		l[s.x] = r
+		if w == 2 {
+			l[s.x+1] = 0
+		}
+		s.x += w
+		if s.x >= s.width {
+			s.x, s.wrap = s.width-1, true
+		}
+	}
+}
+
+// escape handles the escape sequence at the start of data and returns its
+// length, or 0 if it is incomplete.
+func (s *testScreen) escape(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	switch data[1] {
+	case '7':
+		s.saved = [2]int{s.x, s.y}
+		return 2
+	case '8':
+		s.x, s.y, s.wrap = s.saved[0], s.saved[1], false
+		return 2
+	case ']':
+		// OSC, terminated by BEL or ST
+		for i := 2; i < len(data); i++ {
+			if data[i] == '\a' {
+				return i + 1
+			}
+			if data[i] == '\033' && i+1 < len(data) && data[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return 0
+	case '[':
+	default:
+		return 2
+	}
+	i := 2
+	for i < len(data) && (data[i] < 0x40 || data[i] > 0x7e) {
+		i++
+	}
+	if i >= len(data) {
+		return 0
+	}
+	params := string(data[2:i])
+	n, err := strconv.Atoi(params)
+	if err != nil || n == 0 {
+		n = 1
+	}
+	switch data[i] {
+	case 'A':
+		s.y -= n
+		if s.y < 0 {
+			s.y = 0
+		}
+		s.wrap = false
+	case 'B':
+		s.y += n
+		s.line(s.y)
+		s.wrap = false
+	case 'C':
+		s.x, s.wrap = s.x+n, false
+		if s.x >= s.width {
+			s.x = s.width - 1
+		}
+	case 'D':
+		s.x, s.wrap = s.x-n, false
+		if s.x < 0 {
+			s.x = 0
+		}
+	case 'G':
+		s.x, s.wrap = n-1, false
+	case 'K':
+		l := s.line(s.y)
+		for x := s.x; x < s.width; x++ {
+			l[x] = ' '
+		}
+	case 'J':
+		l := s.line(s.y)
+		for x := s.x; x < s.width; x++ {
+			l[x] = ' '
+		}
+		s.lines = s.lines[:s.y+1]
+	}
+	return i + 1
+}
+
+// String returns the screen content with trailing spaces and empty lines
+// removed.
+func (s *testScreen) String() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var lines []string
+	for _, l := range s.lines {
+		lines = append(lines, strings.TrimRight(strings.Replace(string(l), "\x00", "", -1), " "))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Written returns the number of bytes written.
+func (s *testScreen) Written() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.written
+}
+
+// Cursor returns the cursor position.
+func (s *testScreen) Cursor() (x, y int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.x, s.y
+}
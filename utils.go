@@ -58,8 +58,64 @@ const (
 	MetaTranspose
 	MetaShiftTab
 	CharDelete
+	CharBracketedPasteStart // pasted text follows as plain keys
+	CharBracketedPasteEnd
+
+	virtualKeyEnd // end of the virtual key range, not a key
+)
+
+// Special keys decoded from escape sequences. Keys pressed with modifiers
+// are reported combined with ModShift, ModAlt and ModCtrl, for example
+// KeyLeft|ModCtrl. Without modifiers the arrows, Home, End and Delete keys
+// are reported as CharBackward, CharLineStart, CharDelete etc.
+//
+// The keys are above unicode.MaxRune so they can't collide with input,
+// use KeySeq to get their key sequence.
+const (
+	KeyUp rune = unicode.MaxRune + 1 + iota
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPageUp
+	KeyPageDown
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+
+	// told apart from Tab, Enter and Esc by the kitty keyboard protocol
+	KeyCtrlI
+	KeyCtrlM
+	KeyCtrlBracket
+)
+
+const (
+	ModShift rune = 0x100 << iota
+	ModAlt
+	ModCtrl
 )
 
+// metaKeys maps the key following Esc to its virtual Meta key
+var metaKeys = map[rune]rune{
+	'b':           MetaBackward,
+	'f':           MetaForward,
+	'd':           MetaDelete,
+	CharTranspose: MetaTranspose,
+	CharBackspace: MetaBackspace,
+}
+
 // WaitForResume need to call before current process got suspend.
 // It will run a ticker until a long duration is occurs,
 // which means this process is resumed.
@@ -105,57 +161,214 @@ func IsPrintable(key rune) bool {
 
 // translate Esc[X
 func escapeExKey(key *escapeKeyPair) rune {
-	var r rune
+	params := key.params()
+	mod := 1
+	if len(params) > 1 {
+		mod = params[1]
+	}
 	switch key.typ {
 	case 'Z':
-		r = MetaShiftTab
-	case 'D':
-		r = CharBackward
-	case 'C':
-		r = CharForward
-	case 'A':
-		r = CharPrev
-	case 'B':
-		r = CharNext
-	case 'H':
-		r = CharLineStart
-	case 'F':
-		r = CharLineEnd
+		return MetaShiftTab
 	case '~':
-		if key.attr == "3" {
-			r = CharDelete
+		if len(params) == 0 {
+			return 0
+		}
+		switch params[0] {
+		case 200:
+			return CharBracketedPasteStart
+		case 201:
+			return 0
 		}
+		if k, ok := csiTildeKeys[params[0]]; ok {
+			return withModifiers(k, mod)
+		}
+	case 'R':
+		// cursor position report, not F3
 	default:
+		if k, ok := csiFinalKeys[key.typ]; ok {
+			return withModifiers(k, mod)
+		}
 	}
-	return r
+	return 0
 }
 
 // translate EscOX SS3 codes for up/down/etc.
 func escapeSS3Key(key *escapeKeyPair) rune {
-	var r rune
-	switch key.typ {
-	case 'D':
-		r = CharBackward
-	case 'C':
-		r = CharForward
-	case 'A':
-		r = CharPrev
-	case 'B':
-		r = CharNext
-	case 'H':
-		r = CharLineStart
-	case 'F':
-		r = CharLineEnd
-	default:
+	mod := 1
+	if params := key.params(); len(params) > 0 {
+		mod = params[0]
+	}
+	if k, ok := csiFinalKeys[key.typ]; ok {
+		return withModifiers(k, mod)
+	}
+	if key.typ == 'R' {
+		return withModifiers(KeyF3, mod)
+	}
+	return 0
+}
+
+// keys reported as Esc[<mod>X or EscOX
+var csiFinalKeys = map[rune]rune{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'S': KeyF4,
+}
+
+// keys reported as Esc[<n>;<mod>~
+var csiTildeKeys = map[int]rune{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPageUp,
+	6:  KeyPageDown,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+// unmodified keys keep being reported as the control characters of
+// their emacs binding
+var plainKeys = map[rune]rune{
+	KeyUp:     CharPrev,
+	KeyDown:   CharNext,
+	KeyRight:  CharForward,
+	KeyLeft:   CharBackward,
+	KeyHome:   CharLineStart,
+	KeyEnd:    CharLineEnd,
+	KeyDelete: CharDelete,
+}
+
+// withModifiers adds the modifiers of an xterm modifier parameter, which
+// is 1 plus the bits shift 1, alt 2, ctrl 4 and meta 8, to key k.
+func withModifiers(k rune, mod int) rune {
+	m := mod - 1
+	if m <= 0 {
+		if p, ok := plainKeys[k]; ok {
+			return p
+		}
+		return k
+	}
+	if m&1 != 0 {
+		k |= ModShift
+	}
+	if m&(2|8) != 0 {
+		k |= ModAlt
+	}
+	if m&4 != 0 {
+		k |= ModCtrl
+	}
+	return k
+}
+
+// Ctrl keys the kitty keyboard protocol reports apart from the control
+// character they are without it
+var kittyCtrlKeys = map[rune]rune{
+	'i': KeyCtrlI,
+	'm': KeyCtrlM,
+	'[': KeyCtrlBracket,
+}
+
+// legacyKeys maps KeyCtrlI, KeyCtrlM and KeyCtrlBracket to the control
+// character they are without the kitty keyboard protocol.
+var legacyKeys = map[rune]rune{
+	KeyCtrlI:       CharTab,
+	KeyCtrlM:       CharEnter,
+	KeyCtrlBracket: CharEsc,
+}
+
+// legacyKey returns the control character of keys only the kitty keyboard
+// protocol tells apart, other keys are returned as is.
+func legacyKey(r rune) rune {
+	if l, ok := legacyKeys[r]; ok {
+		return l
 	}
 	return r
 }
 
+// translate Esc[<code>;<mods>u of the kitty keyboard protocol, meta is
+// true if the key was pressed with Alt. Key releases and keys without a
+// legacy equivalent, such as lone modifiers, are translated to 0.
+func escapeKittyKey(key *escapeKeyPair) (r rune, meta bool) {
+	fields := strings.Split(key.attr, ";")
+	// the code can be followed by alternate codes, code:shifted:base
+	code, err := strconv.Atoi(strings.Split(fields[0], ":")[0])
+	if err != nil {
+		return 0, false
+	}
+	mod := 1
+	if len(fields) > 1 {
+		// modifiers can be followed by the event type, mods:event
+		sub := strings.Split(fields[1], ":")
+		mod, _ = strconv.Atoi(sub[0])
+		if len(sub) > 1 && sub[1] == "3" {
+			return 0, false
+		}
+	}
+	// shift 1, alt 2, ctrl 4, super 8, hyper 16, meta 32, caps/num lock
+	m := mod - 1
+	if m < 0 {
+		m = 0
+	}
+	r = rune(code)
+	if r >= 0xE000 && r <= 0xF8FF {
+		// functional keys are reported in the private use area
+		return 0, false
+	}
+	if m&1 != 0 {
+		if r == CharTab {
+			r = MetaShiftTab
+		} else {
+			r = unicode.ToUpper(r)
+		}
+	}
+	if m&4 != 0 {
+		if k, ok := kittyCtrlKeys[unicode.ToLower(r)]; ok {
+			return k, m&(2|32) != 0
+		}
+		if r == '?' || (r >= '@' && r <= '_') || (r >= 'a' && r <= 'z') {
+			r = inputrcCtrl(r)
+		}
+	}
+	return r, m&(2|32) != 0
+}
+
 type escapeKeyPair struct {
 	attr string
 	typ  rune
 }
 
+// params returns the numeric parameters of the sequence, missing or
+// invalid ones are 0.
+func (e *escapeKeyPair) params() []int {
+	if e.attr == "" {
+		return nil
+	}
+	sp := strings.Split(e.attr, ";")
+	ps := make([]int, len(sp))
+	for i, p := range sp {
+		ps[i], _ = strconv.Atoi(p)
+	}
+	return ps
+}
+
 func (e *escapeKeyPair) Get2() (int, int, bool) {
 	sp := strings.Split(e.attr, ";")
 	if len(sp) < 2 {
@@ -175,8 +388,13 @@ func (e *escapeKeyPair) Get2() (int, int, bool) {
 func readEscKey(r rune, reader *bufio.Reader) *escapeKeyPair {
 	p := escapeKeyPair{}
 	buf := bytes.NewBuffer(nil)
+	if strings.ContainsRune("<=>?", r) {
+		// private parameter prefix used in replies
+		buf.WriteRune(r)
+		r, _, _ = reader.ReadRune()
+	}
 	for {
-		if r == ';' {
+		if r == ';' || r == ':' {
 		} else if unicode.IsNumber(r) {
 		} else {
 			p.typ = r
@@ -191,17 +409,10 @@ func readEscKey(r rune, reader *bufio.Reader) *escapeKeyPair {
 
 // translate EscX to Meta+X
 func escapeKey(r rune, reader *bufio.Reader) rune {
+	if m, ok := metaKeys[r]; ok {
+		return m
+	}
 	switch r {
-	case 'b':
-		r = MetaBackward
-	case 'f':
-		r = MetaForward
-	case 'd':
-		r = MetaDelete
-	case CharTranspose:
-		r = MetaTranspose
-	case CharBackspace:
-		r = MetaBackspace
 	case 'O':
 		d, _, _ := reader.ReadRune()
 		switch d {
@@ -228,8 +439,9 @@ func SplitByLine(prompt, rs []rune, offset, screenWidth, nextWidth int) [][]rune
 	prs := append(prompt, rs...)
 	si := 0
 	currentWidth := offset
+	widths := graphemeWidths(prs)
 	for i, r := range prs {
-		w := runes.Width(r)
+		w := widths[i]
 		if r == '\n' {
 			ret = append(ret, prs[si:i+1])
 			si = i + 1
@@ -150,15 +150,33 @@ func (o *opVim) HandleVimNormal(r rune, readNext func() rune) (t rune) {
 	return 0
 }
 
+// IsVimNormalMode returns true if vim mode is enabled and in normal mode.
+func (o *opVim) IsVimNormalMode() bool {
+	return o.IsEnableVimMode() && o.vimMode == VIM_NORMAL
+}
+
 func (o *opVim) EnterVimInsertMode() {
 	o.vimMode = VIM_INSERT
+	o.refreshStatus()
 }
 
 func (o *opVim) ExitVimInsertMode() {
 	o.vimMode = VIM_NORMAL
+	o.refreshStatus()
+}
+
+// refreshStatus redraws the line so that Config.StatusFunc can show the
+// new mode.
+func (o *opVim) refreshStatus() {
+	if o.op.GetConfig().StatusFunc != nil {
+		o.op.buf.update(nil)
+	}
 }
 
 func (o *opVim) HandleVim(r rune, readNext func() rune) rune {
+	if r == KeyCtrlBracket {
+		r = CharEsc
+	}
 	if o.vimMode == VIM_NORMAL {
 		return o.HandleVimNormal(r, readNext)
 	}